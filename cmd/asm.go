@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/asm"
+	"github.com/juanpablocruz/sim8086/pkg/memimage"
+	"github.com/juanpablocruz/sim8086/pkg/options"
+	"github.com/juanpablocruz/sim8086/pkg/vm"
+)
+
+// asmCommand implements sim8086 asm in.asm -o out.bin, writing the flat
+// binary NASM would, next to the source without its extension by default.
+func asmCommand(args []string) int {
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	outFlag := fs.String("o", "", "-o out.bin file to write, defaults to the source without its extension")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sim8086 asm in.asm [-o out.bin]")
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	bin, err := assembleFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	out := *outFlag
+	if out == "" {
+		out = strings.TrimSuffix(args[0], filepath.Ext(args[0]))
+		if out == args[0] {
+			out += ".bin"
+		}
+	}
+	if err := os.WriteFile(out, bin.Data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runCommand implements sim8086 run foo.asm, assembling the source and
+// executing it as -exec does a binary.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	regDiff := fs.Bool("regDiff", false, "-regDiff print the result of each instruction")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sim8086 run foo.asm [-regDiff]")
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	bin, err := assembleFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+
+	flags := uint32(0)
+	if *regDiff {
+		flags |= options.SimFlag_NoRegisterDiffs
+	}
+
+	c := vm.New()
+	img := &memimage.Image{Segments: []memimage.Segment{{Address: bin.Origin, Data: bin.Data}}}
+	if err := c.LoadImage(img); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 1
+	}
+	for n, instr := range bin.Instructions {
+		if err := c.Exec(instr, flags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s:%d: %s\n", args[0], bin.Lines[n], err)
+			return 1
+		}
+	}
+	var out bytes.Buffer
+	out.WriteString("\nFinal registers:\n")
+	c.PrintRegisters(&out, 4)
+	fmt.Println(out.String())
+	return 0
+}
+
+func assembleFile(fileName string) (*asm.Binary, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	prog, err := asm.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	bin, err := asm.Assemble(prog)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return bin, nil
+}
+
+// parseInterspersed parses fs from args allowing flags after the file
+// names, as in sim8086 asm in.asm -o out.bin, and returns the file names.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	files := []string{}
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return files
+		}
+		files = append(files, args[0])
+		args = args[1:]
+	}
+}
@@ -2,29 +2,72 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/juanpablocruz/sim8086/pkg/cfg"
+	"github.com/juanpablocruz/sim8086/pkg/disasm"
 	"github.com/juanpablocruz/sim8086/pkg/instruction"
 	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/memimage"
+	"github.com/juanpablocruz/sim8086/pkg/mz"
 	"github.com/juanpablocruz/sim8086/pkg/options"
 	"github.com/juanpablocruz/sim8086/pkg/reader"
 	"github.com/juanpablocruz/sim8086/pkg/vm"
+	"github.com/juanpablocruz/sim8086/pkg/xref"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "asm":
+			os.Exit(asmCommand(os.Args[2:]))
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "verify":
+			os.Exit(verifyCommand(os.Args[2:]))
+		}
+	}
+
 	// execFlag := flag.Bool("exec", false, "-exec to interprete the code")
 	showClocksFlag := flag.Bool("showclocks", false, "-showclocks to show cycles for each instruction")
 	dumpMemoryFlag := flag.Bool("dump", false, "-dump to dump memory")
 	executeSym := flag.Bool("exec", false, "-exec run the simulation")
 	regDiff := flag.Bool("regDiff", false, "-regDiff print the result of each instruction")
+	emitDb := flag.Bool("db", false, "-db emit undecodable bytes as db and keep disassembling")
+	labelsFlag := flag.Bool("labels", false, "-labels name jump and loop targets instead of printing $+N")
+	orgFlag := flag.String("org", "", "-org 0x100 or -org 0x1000:0x0100 address the code is loaded at")
+	flowFlag := flag.Bool("flow", false, "-flow follow control flow from the entry points, unreached bytes become db")
+	listingFlag := flag.Bool("listing", false, "-listing print address and encoded bytes columns before each instruction")
+	syntaxFlag := flag.String("syntax", "nasm", "-syntax nasm|masm|att assembler syntax of the disassembly")
+	formatFlag := flag.String("format", "text", "-format text|json|jsonl|dot|xref output format of the disassembly, dot being the control-flow graph and xref the cross-reference report")
+	hexFlag := flag.Bool("hex", false, "-hex print immediates and displacements in hexadecimal")
+	inputFlag := flag.String("input", "auto", "-input auto|bin|ihex|srec input file format, auto going by the file extension")
+	rawFlag := flag.Bool("raw", false, "-raw treat MZ executables as flat binaries")
+	entryFlag := flag.String("entry", "", "-entry 0x100,0x180 entry points for -flow, defaults to the origin")
 	flag.Parse()
 	args := flag.Args()
 
 	if len(args) < 1 {
 		fmt.Println("Error: Missing required input file")
-		fmt.Println("Usage: sim8086 asmfile")
+		fmt.Println("Usage: sim8086 asmfile (- reads from stdin)")
+		os.Exit(1)
+	}
+
+	segment, origin, hasSegment, err := parseOrigin(*orgFlag)
+	if err != nil {
+		fmt.Printf("Error: invalid -org %q: %s\n", *orgFlag, err)
+		os.Exit(1)
+	}
+
+	formatter, err := instruction.NewFormatter(*syntaxFlag, instruction.FormatOptions{Hex: *hexFlag})
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
 		os.Exit(1)
 	}
 
@@ -37,29 +80,119 @@ func main() {
 	if *regDiff {
 		flags |= options.SimFlag_NoRegisterDiffs
 	}
+	if *emitDb {
+		flags |= options.SimFlag_EmitUndecodable
+	}
 
-	rd, err := reader.New(fileName)
+	var rd *reader.Reader
+	if fileName == "-" {
+		rd, err = reader.NewFromReader(os.Stdin)
+	} else {
+		rd, err = reader.New(fileName)
+	}
 	if err != nil {
 		panic(err)
 	}
+	rd.Origin = origin
+
+	// MZ executables are disassembled within their entry code segment,
+	// addresses being offsets from CS
+	entry := origin
+	var exe *mz.File
+	if !*rawFlag && mz.IsMZ(rd.Data) {
+		exe, err = mz.Parse(rd.Data)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		base := min(int(exe.Header.CS)<<4, len(exe.LoadModule))
+		rd.Data = exe.LoadModule[base:]
+		rd.Origin = 0
+		segment, origin, hasSegment = int(exe.Header.CS), 0, true
+		entry = int(exe.Header.IP)
+		if !*flowFlag {
+			rd.SegmentOffset = entry
+		}
+	}
 	if *dumpMemoryFlag {
 		fmt.Printf("%s\n", rd.Dump())
 	}
 	defer rd.Close()
 
+	// HEX and S-record images are decoded one contiguous segment at a
+	// time, so that the gaps between them are never read as code
+	sources := []*reader.Reader{rd}
+	defaultEntries := []int{entry}
+	var img *memimage.Image
+	switch inputFormat(*inputFlag, fileName) {
+	case "ihex":
+		img, err = memimage.ParseIntelHex(bytes.NewReader(rd.Data))
+	case "srec":
+		img, err = memimage.ParseSRecord(bytes.NewReader(rd.Data))
+	case "bin":
+	default:
+		err = fmt.Errorf("unknown -input %q", *inputFlag)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if img != nil {
+		sources, defaultEntries = sources[:0], defaultEntries[:0]
+		for _, s := range img.Segments {
+			sources = append(sources, s.Reader())
+			defaultEntries = append(defaultEntries, s.Address)
+		}
+		if img.HasEntry {
+			defaultEntries = []int{img.Entry}
+		}
+		// the text disassembly lists them in its header instead
+		if *formatFlag != "text" || *executeSym {
+			for _, gap := range gapReport(img) {
+				fmt.Fprintln(os.Stderr, gap)
+			}
+		}
+	}
+
 	allInstr := []instruction.Instruction{}
+	var regions []disasm.Region
+	var decodeErr error
+
+	for _, src := range sources {
+		if *flowFlag {
+			entries, err := parseEntries(*entryFlag, defaultEntries)
+			if err != nil {
+				fmt.Printf("Error: invalid -entry %q: %s\n", *entryFlag, err)
+				os.Exit(1)
+			}
+			t := disasm.Traverse(src, entries)
+			allInstr = append(allInstr, t.Instructions...)
+			regions = append(regions, t.Regions...)
+			continue
+		}
 
-	l := lexer.New(rd)
-	for {
-		in := l.NextInstruction()
-		if in.Op == 0 {
-			break
+		instrs, err := lexer.NewWithFlags(src, flags).All()
+		allInstr = append(allInstr, instrs...)
+		if decodeErr == nil {
+			decodeErr = err
 		}
-		allInstr = append(allInstr, in)
 	}
+	// report the failure after whatever could be decoded has been printed
+	defer func() {
+		if decodeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", decodeErr)
+			os.Exit(1)
+		}
+	}()
 
 	if *executeSym {
 		c := vm.New()
+		if img != nil {
+			if err := c.LoadImage(img); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				os.Exit(1)
+			}
+		}
 		for _, instr := range allInstr {
 			err := c.Exec(instr, flags)
 			if err != nil {
@@ -70,19 +203,195 @@ func main() {
 		out.WriteString("\nFinal registers:\n")
 		c.PrintRegisters(&out, 4)
 		fmt.Println(out.String())
-	} else {
+	} else if *formatFlag == "json" || *formatFlag == "jsonl" {
+		labels := map[int]string{}
+		if *labelsFlag {
+			labels = disasm.Labels(allInstr)
+		}
+		for n, instr := range allInstr {
+			allInstr[n] = disasm.ApplyLabel(instr, labels)
+		}
 
-		fmt.Printf("; %s dissasembly:\n", fileName)
-		fmt.Println("bits 16")
+		enc := json.NewEncoder(os.Stdout)
+		if *formatFlag == "json" {
+			enc.SetIndent("", "  ")
+			err = enc.Encode(allInstr)
+		} else {
+			for _, instr := range allInstr {
+				if err = enc.Encode(instr); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	} else if *formatFlag == "dot" {
+		labels := map[int]string{}
+		if *labelsFlag {
+			labels = disasm.Labels(allInstr)
+		}
+		for n, instr := range allInstr {
+			allInstr[n] = disasm.ApplyLabel(instr, labels)
+		}
+
+		if err := cfg.Build(allInstr).WriteDOT(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	} else if *formatFlag == "xref" {
+		if err := xref.Build(allInstr).WriteReport(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	} else if *formatFlag == "text" {
+
+		fmt.Println(formatter.Comment(fileName + " dissasembly:"))
+		printHeader(*syntaxFlag, origin, *orgFlag != "")
+		if exe != nil {
+			h := exe.Header
+			fmt.Println(formatter.Comment(fmt.Sprintf("MZ entry %04x:%04x, stack %04x:%04x, %d relocations, load module of %d bytes", h.CS, h.IP, h.SS, h.SP, len(exe.Relocations), len(exe.LoadModule))))
+		}
 		fmt.Println("")
+		if img != nil {
+			for _, gap := range gapReport(img) {
+				fmt.Println(formatter.Comment(gap))
+			}
+		}
+		for _, region := range regions {
+			fmt.Println(formatter.Comment(fmt.Sprintf("%s %s-%s", region.Kind, formatAddress(segment, region.Start, hasSegment), formatAddress(segment, region.End-1, hasSegment))))
+		}
+		if len(regions) > 0 {
+			fmt.Println("")
+		}
+
+		labels := map[int]string{}
+		if *labelsFlag {
+			labels = disasm.Labels(allInstr)
+		}
 
 		var out bytes.Buffer
 		for _, instr := range allInstr {
-			out.WriteString(instr.String())
+			if label, ok := labels[instr.Address]; ok {
+				out.WriteString(label + ":\n")
+			}
+			instr = disasm.ApplyLabel(instr, labels)
+			if *listingFlag {
+				out.WriteString(fmt.Sprintf("%-11s  %-20s  ", formatAddress(segment, instr.Address, hasSegment), fmt.Sprintf("% x", instr.Bytes)))
+			}
+			out.WriteString(formatter.Format(instr))
+			if target, ok := instr.BranchTarget(); ok && (*orgFlag != "" || exe != nil) {
+				out.WriteString(" " + formatter.Comment(formatAddress(segment, target, hasSegment)))
+			}
+			if exe != nil {
+				for _, at := range exe.RelocatedIn(segment<<4+instr.Address, instr.Size) {
+					value, _ := exe.Word(at)
+					out.WriteString(" " + formatter.Comment(fmt.Sprintf("relocated segment 0x%04x", value)))
+				}
+			}
 			out.WriteString("\n")
 		}
 
 		fmt.Println(out.String())
+	} else {
+		fmt.Printf("Error: unknown -format %q\n", *formatFlag)
+		os.Exit(1)
 	}
 	// DisAsm8086(uint32(len(inpt)), MainMemory, flags, timing)
 }
+
+// printHeader writes the directives that put the assembler in 16-bit mode
+// at the right origin.
+func printHeader(syntax string, origin int, hasOrigin bool) {
+	switch strings.ToLower(syntax) {
+	case "masm", "tasm":
+		fmt.Println(".8086")
+		if hasOrigin {
+			fmt.Printf("org %04xh\n", origin)
+		}
+	case "att", "gas":
+		fmt.Println(".code16")
+		if hasOrigin {
+			fmt.Printf("# loaded at 0x%04x\n", origin)
+		}
+	default:
+		fmt.Println("bits 16")
+		if hasOrigin {
+			fmt.Printf("org 0x%04x\n", origin)
+		}
+	}
+}
+
+// parseOrigin accepts a plain offset ("0x100", "256") or a segment:offset
+// pair ("0x1000:0x0100").
+func parseOrigin(s string) (segment int, offset int, hasSegment bool, err error) {
+	if s == "" {
+		return 0, 0, false, nil
+	}
+
+	seg, off, hasSegment := strings.Cut(s, ":")
+	if !hasSegment {
+		off = seg
+	} else {
+		v, err := strconv.ParseUint(seg, 0, 16)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		segment = int(v)
+	}
+
+	v, err := strconv.ParseUint(off, 0, 16)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return segment, int(v), hasSegment, nil
+}
+
+// parseEntries reads a comma separated list of entry point addresses,
+// falling back to defaults when the list is empty.
+func parseEntries(s string, defaults []int) ([]int, error) {
+	if s == "" {
+		return defaults, nil
+	}
+
+	entries := []int{}
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(field), 0, 20)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, int(v))
+	}
+	return entries, nil
+}
+
+func gapReport(img *memimage.Image) []string {
+	lines := []string{}
+	for _, gap := range img.Gaps() {
+		lines = append(lines, fmt.Sprintf("gap 0x%05x-0x%05x (%d bytes not loaded)", gap.Start, gap.End-1, gap.End-gap.Start))
+	}
+	return lines
+}
+
+// inputFormat resolves -input auto from the extension of fileName.
+func inputFormat(input string, fileName string) string {
+	if input != "auto" {
+		return input
+	}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".hex", ".ihx", ".ihex":
+		return "ihex"
+	case ".srec", ".s19", ".s28", ".s37", ".mot":
+		return "srec"
+	default:
+		return "bin"
+	}
+}
+
+func formatAddress(segment int, offset int, hasSegment bool) string {
+	if hasSegment {
+		return fmt.Sprintf("0x%04x:0x%04x", segment, offset)
+	}
+	return fmt.Sprintf("0x%04x", offset)
+}
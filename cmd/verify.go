@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/juanpablocruz/sim8086/pkg/verify"
+)
+
+// verifyCommand implements sim8086 verify file..., checking that the
+// disassembly of every file assembles back into the file, byte for byte.
+// Directories are walked for the binaries in them.
+func verifyCommand(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	orgFlag := flags.String("org", "", "-org 0x100 address the code is loaded at")
+	equivalentFlag := flags.Bool("equivalent", false, "-equivalent accept instructions that reassemble to another encoding of the same instruction, a shorter displacement included")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sim8086 verify [-org 0x100] [-equivalent] file|dir...")
+		flags.PrintDefaults()
+	}
+	args = parseInterspersed(flags, args)
+	if len(args) == 0 {
+		flags.Usage()
+		return 1
+	}
+	_, origin, _, err := parseOrigin(*orgFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -org %q: %s\n", *orgFlag, err)
+		return 1
+	}
+
+	status := 0
+	fileNames := []string{}
+	for _, arg := range args {
+		files, err := binaries(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			status = 1
+		}
+		fileNames = append(fileNames, files...)
+	}
+	if len(fileNames) == 0 && status == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no binaries to verify")
+		return 1
+	}
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			status = 1
+			continue
+		}
+		report, err := verify.Check(data, origin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", fileName, err)
+			status = 1
+			continue
+		}
+
+		summary := fmt.Sprintf("%d instructions", report.Instructions)
+		if n := report.Equivalent(); n > 0 {
+			summary += fmt.Sprintf(", %d reassembled to another encoding", n)
+		}
+		m := report.First(*equivalentFlag)
+		if m == nil {
+			fmt.Printf("ok    %s (%s)\n", fileName, summary)
+			continue
+		}
+		status = 1
+		fmt.Printf("FAIL  %s (%s, %d mismatches)\n", fileName, summary, len(report.Mismatches))
+		m.WriteDiff(os.Stdout)
+	}
+	return status
+}
+
+// binaries returns path, or the files under it when it is a directory,
+// leaving out the .asm sources the binaries were assembled from.
+func binaries(path string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) == ".asm" {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,375 @@
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+// maxPasses bounds the layout passes. Every pass but the first sees the
+// addresses of the one before, so only programs whose instruction sizes
+// keep trading places with their addresses need more than a handful.
+const maxPasses = 32
+
+// table is built once, assembling a line being cheaper than building it.
+var table = sync.OnceValue(instruction.New8086InstructionTable)
+
+// Binary is an assembled program.
+type Binary struct {
+	// Origin is the address Data is laid out from, as set by org.
+	Origin int
+	Data   []byte
+	// Instructions are the encoded instructions as they decode, with
+	// the symbols they were written with, and Lines the source line of
+	// every one of them.
+	Instructions []instruction.Instruction
+	Lines        []int
+	// Symbols holds the value of every label and equ.
+	Symbols map[string]int
+}
+
+// Assemble lays p out and encodes it. Symbols may be used before they are
+// defined: the program is laid out again, with the values found by the
+// previous pass, until no symbol moves.
+func Assemble(p *Program) (*Binary, error) {
+	a := assembler{
+		prog:  p,
+		table: table(),
+		near:  map[int]bool{},
+		at:    map[int][]string{},
+	}
+	for name, n := range p.Labels {
+		a.at[n] = append(a.at[n], name)
+	}
+
+	var prev map[string]int
+	for range maxPasses {
+		bin, err := a.pass(prev)
+		if prev != nil && maps.Equal(prev, bin.Symbols) {
+			if err != nil {
+				return nil, err
+			}
+			return bin, nil
+		}
+		prev = bin.Symbols
+	}
+	return nil, fmt.Errorf("addresses did not settle after %d passes", maxPasses)
+}
+
+type assembler struct {
+	prog  *Program
+	table instruction.InstructionTable
+	// near marks the jumps whose target was out of reach of a short jump
+	// in an earlier pass. They stay near, so that jumps can only grow and
+	// the layout settles.
+	near map[int]bool
+	// at lists the labels defined at every statement
+	at map[int][]string
+}
+
+// pass lays the program out once, using prev for the symbols that are not
+// defined yet. It goes all the way through whatever it finds, returning
+// the first error along with the layout.
+func (a *assembler) pass(prev map[string]int) (*Binary, error) {
+	bin := &Binary{Symbols: map[string]int{}}
+	var firstErr error
+	fail := func(s Statement, err error) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("line %d: %w", s.Line, err)
+		}
+	}
+	lookup := func(name string) (int, bool) {
+		if v, ok := bin.Symbols[name]; ok {
+			return v, true
+		}
+		v, ok := prev[name]
+		return v, ok
+	}
+
+	origin, err := a.origin(lookup)
+	if err != nil {
+		return bin, err
+	}
+	bin.Origin = origin
+
+	starts := []int{}
+	addr := origin
+	for n, s := range a.prog.Statements {
+		for _, name := range a.at[n] {
+			bin.Symbols[name] = addr
+		}
+		sc := scope{here: addr, origin: origin, lookup: lookup}
+
+		switch s.Kind {
+		case Statement_Equ:
+			v, err := evaluate(s.Expr, sc)
+			if err != nil {
+				fail(s, err)
+			}
+			bin.Symbols[s.Name] = v
+		case Statement_Align:
+			pad, fill, err := alignment(s, sc)
+			if err != nil {
+				fail(s, err)
+			}
+			for range pad {
+				bin.Data = append(bin.Data, fill)
+			}
+		case Statement_Data, Statement_Instruction:
+			count := 1
+			if s.Times != "" {
+				count, err = evaluate(s.Times, sc)
+				if err == nil && count < 0 {
+					err = fmt.Errorf("times count %d is negative", count)
+				}
+				if err != nil {
+					fail(s, err)
+					count = 0
+				}
+			}
+			for range count {
+				sc.here = origin + len(bin.Data)
+				var out []byte
+				var err error
+				if s.Kind == Statement_Data {
+					out, err = data(s, sc)
+				} else {
+					var in instruction.Instruction
+					in, out, err = a.instruction(n, s.Instruction, sc)
+					if err == nil {
+						starts = append(starts, len(bin.Data))
+						bin.Instructions = append(bin.Instructions, in)
+						bin.Lines = append(bin.Lines, s.Line)
+					}
+				}
+				if err != nil {
+					fail(s, err)
+				}
+				bin.Data = append(bin.Data, out...)
+			}
+		}
+		addr = origin + len(bin.Data)
+	}
+	for _, name := range a.at[len(a.prog.Statements)] {
+		bin.Symbols[name] = addr
+	}
+
+	// now that the data is complete, point the instructions at their bytes
+	for n, start := range starts {
+		in := &bin.Instructions[n]
+		in.Bytes = bin.Data[start : start+in.Size : start+in.Size]
+		in.Prefixes = in.Bytes[:len(in.Prefixes)]
+	}
+	return bin, firstErr
+}
+
+// origin evaluates the org of the program, 0 when there is none.
+func (a *assembler) origin(lookup func(string) (int, bool)) (int, error) {
+	origin, found := 0, false
+	for _, s := range a.prog.Statements {
+		if s.Kind != Statement_Org {
+			continue
+		}
+		if found {
+			return 0, fmt.Errorf("line %d: org given more than once", s.Line)
+		}
+		v, err := evaluate(s.Expr, scope{lookup: lookup})
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", s.Line, err)
+		}
+		if v < 0 || v > 0xffff {
+			return 0, fmt.Errorf("line %d: org 0x%x is outside the 64KB segment", s.Line, v)
+		}
+		origin, found = v, true
+	}
+	return origin, nil
+}
+
+// alignment returns how many fill bytes take the statement to the next
+// multiple of its boundary, counted from the start of the section.
+func alignment(s Statement, sc scope) (int, byte, error) {
+	boundary, err := evaluate(s.Expr, sc)
+	if err != nil {
+		return 0, 0, err
+	}
+	if boundary <= 0 || boundary&(boundary-1) != 0 {
+		return 0, 0, fmt.Errorf("align %d is not a power of two", boundary)
+	}
+	fill, err := evaluate(s.Fill, sc)
+	if err != nil {
+		return 0, 0, err
+	}
+	if fill < -128 || fill > 255 {
+		return 0, 0, fmt.Errorf("align fill %d does not fit in a byte", fill)
+	}
+	offset := sc.here - sc.origin
+	return (boundary - offset%boundary) % boundary, byte(fill), nil
+}
+
+// data encodes the values of a db or dw.
+func data(s Statement, sc scope) ([]byte, error) {
+	out := []byte{}
+	var firstErr error
+	for _, item := range s.Items {
+		if text, ok := quoted(item); ok {
+			out = append(out, text...)
+			if s.Width == 2 && len(text)%2 == 1 {
+				out = append(out, 0)
+			}
+			continue
+		}
+		v, err := evaluate(item, sc)
+		if err == nil && (v < -1<<(8*s.Width-1) || v >= 1<<(8*s.Width)) {
+			err = fmt.Errorf("value %d does not fit in %d bits", v, 8*s.Width)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for n := range s.Width {
+			out = append(out, byte(v>>(8*n)))
+		}
+	}
+	return out, firstErr
+}
+
+// quoted returns the text of a string item. Strings of one or two
+// characters are left to be read as character constants in dw.
+func quoted(item string) (string, bool) {
+	if len(item) < 2 || strings.IndexByte("'\"`", item[0]) < 0 || item[len(item)-1] != item[0] {
+		return "", false
+	}
+	return item[1 : len(item)-1], true
+}
+
+// instruction resolves the symbols of in and encodes it. Jumps whose
+// width was not given are made short when the target is known to be in
+// reach, near otherwise. A symbol that is not defined yet is taken as
+// 0, or as $ for a jump target, for the bytes returned along with the
+// error to have the size the instruction will likely end up with.
+func (a *assembler) instruction(n int, in instruction.Instruction, sc scope) (instruction.Instruction, []byte, error) {
+	in.Address = sc.here
+	var target *instruction.InstructionOperand
+	targetAddr := 0
+	var undefined error
+
+	resolve := func(expr string, guess int) (int, error) {
+		v, err := evaluate(expr, sc)
+		if isUndefined(err) {
+			if undefined == nil {
+				undefined = err
+			}
+			return guess, nil
+		}
+		return v, err
+	}
+
+	for _, op := range []*instruction.InstructionOperand{&in.Reg, &in.RM} {
+		switch {
+		case op.Type == instruction.Operand_Immediate && op.Label != "" && isRelative(*op):
+			v, err := resolve(op.Label, sc.here)
+			if err != nil {
+				return in, nil, err
+			}
+			target, targetAddr = op, v
+		case op.Type == instruction.Operand_Immediate && op.Label != "":
+			v, err := resolve(op.Label, 0)
+			if err != nil {
+				return in, nil, err
+			}
+			op.Value = v
+			if err := fitImmediate(&in, &op.Immediate); err != nil {
+				return in, nil, err
+			}
+		case op.Type == instruction.Operand_Memory && op.DisplacementLabel != "":
+			v, err := resolve(op.DisplacementLabel, 0)
+			if err != nil {
+				return in, nil, err
+			}
+			mem, mode, err := instruction.ResolveEffectiveAddress([2]string{op.Terms[0].Name, op.Terms[1].Name}, v)
+			if err != nil {
+				return in, nil, err
+			}
+			mem.Segment, mem.DisplacementLabel = op.Segment, op.DisplacementLabel
+			*op, in.Mode = mem, mode
+		}
+	}
+
+	if target == nil {
+		out, err := a.table.Encode(in)
+		if err != nil {
+			return in, nil, err
+		}
+		return a.decoded(in, out, undefined)
+	}
+
+	widths := []int{0, int(instruction.Bits_W)}
+	if target.Immediate.Flags&int(instruction.Bits_W) != 0 {
+		widths = widths[1:]
+	} else if a.near[n] || undefined != nil {
+		widths = []int{int(instruction.Bits_W), 0}
+	}
+	var short []byte
+	for _, width := range widths {
+		target.Immediate.Flags = target.Immediate.Flags&^int(instruction.Bits_W) | width
+		target.Value = 0
+		out, err := a.table.Encode(in)
+		if err != nil {
+			continue
+		}
+		disp := targetAddr - (sc.here + len(out))
+		if width != 0 {
+			disp = int(int16(disp))
+		}
+		target.Value = disp
+		final, err := a.table.Encode(in)
+		if err != nil {
+			short = out
+			continue
+		}
+		if width != 0 && short != nil {
+			a.near[n] = true
+		}
+		return a.decoded(in, final, undefined)
+	}
+	if short != nil {
+		return in, short, fmt.Errorf("jump target 0x%x out of range", targetAddr)
+	}
+	_, err := a.table.Encode(in)
+	return in, nil, err
+}
+
+// decoded returns what out decodes to, keeping the symbols in was written
+// with for it to print the same, and passes err along. A prefix written on
+// its own line does not decode without what it prefixes, and is returned
+// as it was written.
+func (a *assembler) decoded(in instruction.Instruction, out []byte, err error) (instruction.Instruction, []byte, error) {
+	r := reader.NewFromBytes(out)
+	r.Origin = in.Address
+	r.ReadByte()
+	decoded, decodeErr := a.table.DecodeInstruction(r)
+	if decodeErr != nil {
+		in.Size = len(out)
+		return in, out, err
+	}
+	for _, ops := range [][2]*instruction.InstructionOperand{{&decoded.Reg, &in.Reg}, {&decoded.RM, &in.RM}} {
+		if ops[0].Type == ops[1].Type {
+			ops[0].Label = ops[1].Label
+			ops[0].DisplacementLabel = ops[1].DisplacementLabel
+		}
+	}
+	return decoded, out, err
+}
+
+func isRelative(op instruction.InstructionOperand) bool {
+	return op.Immediate.Flags&instruction.Immediate_RelativeJumpDisplacement != 0
+}
+
+func isUndefined(err error) bool {
+	var undefined *undefinedError
+	return errors.As(err, &undefined)
+}
@@ -0,0 +1,137 @@
+package asm_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/asm"
+)
+
+func assemble(src string) (*asm.Binary, error) {
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	return asm.Assemble(prog)
+}
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []byte
+	}{
+		{"instructions", "mov cx, bx\nadd cx, 5", []byte{0x89, 0xd9, 0x83, 0xc1, 0x05}},
+		{"backward jump", "top: dec cx\njnz top", []byte{0x49, 0x75, 0xfd}},
+		{"forward jump", "jmp end\ncbw\nend:", []byte{0xeb, 0x01, 0x98}},
+		{"near jump", "jmp end\ntimes 200 db 0\nend:", append([]byte{0xe9, 0xc8, 0x00}, make([]byte, 200)...)},
+		{"explicit near", "jmp near end\nend:", []byte{0xe9, 0x00, 0x00}},
+		{"call", "call f\nf: ret", []byte{0xe8, 0x00, 0x00, 0xc3}},
+		{"dollar", "jmp $", []byte{0xeb, 0xfe}},
+		{"db", "db 1, -1, 'ab', \"c\"", []byte{0x01, 0xff, 'a', 'b', 'c'}},
+		{"dw", "dw 0x1234, 'a', 'abc'", []byte{0x34, 0x12, 'a', 0x00, 'a', 'b', 'c', 0x00}},
+		{"times", "times 3 db 7", []byte{7, 7, 7}},
+		{"times expression", "db 1\ntimes 4-($-$$) db 0", []byte{1, 0, 0, 0}},
+		{"times instruction", "times 2 inc ax", []byte{0x40, 0x40}},
+		{"nop", "times 3 nop", []byte{0x90, 0x90, 0x90}},
+		{"equ", "n equ 3*(2+1)\nmov al, n", []byte{0xb0, 0x09}},
+		{"forward equ", "mov ax, n\nn: equ 0x1234", []byte{0xb8, 0x34, 0x12}},
+		{"expressions", "dw 1<<4 | 1, ~0 & 0xff, 7/2, 7%2, -(2-5)", []byte{0x11, 0, 0xff, 0, 3, 0, 1, 0, 3, 0}},
+		{"org", "org 0x100\nmov ax, data\ndata: dw $$", []byte{0xb8, 0x03, 0x01, 0x00, 0x01}},
+		{"align", "db 1\nalign 4\ndb 2", []byte{1, 0x90, 0x90, 0x90, 2}},
+		{"align fill", "org 0x100\ndb 1\nalign 2, db 0\ndb 2", []byte{1, 0, 2}},
+		{"label address", "mov ax, [table + 2]\ntable: dw 0", []byte{0xa1, 0x05, 0x00, 0x00, 0x00}},
+		{"label displacement", "mov al, [bx + table]\ntable: db 0", []byte{0x8a, 0x47, 0x03, 0x00}},
+		{"label displacement wide", "mov al, [bx + table]\ntimes 200 db 0\ntable:", append([]byte{0x8a, 0x87, 0xcc, 0x00}, make([]byte, 200)...)},
+		{"local labels", "a:\n.l: jmp .l\nb:\n.l: jmp .l\njmp a.l", []byte{0xeb, 0xfe, 0xeb, 0xfe, 0xeb, 0xfa}},
+		{"named data", "mov al, [msg]\nmsg db 'x'", []byte{0xa0, 0x03, 0x00, 'x'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin, err := assemble(tt.src)
+			if err != nil {
+				t.Fatalf("Assemble() returned %v", err)
+			}
+			if !bytes.Equal(bin.Data, tt.want) {
+				t.Errorf("Data. got=% x want=% x", bin.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssemble_Symbols(t *testing.T) {
+	bin, err := assemble("org 0x100\nstart: cbw\n.loop: jmp .loop\nsize equ $ - start\nend:")
+	if err != nil {
+		t.Fatalf("Assemble() returned %v", err)
+	}
+	want := map[string]int{"start": 0x100, "start.loop": 0x101, "size": 3, "end": 0x103}
+	for name, v := range want {
+		if got, ok := bin.Symbols[name]; !ok || got != v {
+			t.Errorf("Symbols[%s]. got=%#x,%v want=%#x", name, got, ok, v)
+		}
+	}
+	if bin.Origin != 0x100 {
+		t.Errorf("Origin. got=%#x want=0x100", bin.Origin)
+	}
+	if len(bin.Instructions) != 2 || bin.Instructions[1].Address != 0x101 || bin.Instructions[1].String() != "jmp start.loop" {
+		t.Errorf("Instructions. got=%v", bin.Instructions)
+	}
+	if len(bin.Lines) != 2 || bin.Lines[1] != 3 {
+		t.Errorf("Lines. got=%v want=[2 3]", bin.Lines)
+	}
+}
+
+func TestAssemble_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"jmp nowhere", "line 1: undefined symbol nowhere"},
+		{"je far_away\ntimes 200 db 0\nfar_away:", "line 1: jump target 0xca out of range"},
+		{"db 256", "line 1: value 256 does not fit in 8 bits"},
+		{"mov al, n\nn equ 300", "line 1: value 300 does not fit in 8 bits"},
+		{"times -1 db 0", "line 1: times count -1 is negative"},
+		{"align 3", "line 1: align 3 is not a power of two"},
+		{"org 1\norg 2", "line 2: org given more than once"},
+		{"db 1/0", "line 1: division by zero in expression 1/0"},
+		{"db (1", "line 1: missing ) in expression (1"},
+		{"mov ax, [bx + bad]", "line 1: undefined symbol bad"},
+	}
+	for _, tt := range tests {
+		_, err := assemble(tt.src)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Assemble(%q) error. got=%v want=%s", tt.src, err, tt.err)
+		}
+	}
+}
+
+// TestAssemble_Listings rebuilds the part1 binaries from their sources.
+func TestAssemble_Listings(t *testing.T) {
+	sources, _ := filepath.Glob("../../part1/*.asm")
+	if len(sources) == 0 {
+		t.Skip("no listings")
+	}
+	for _, source := range sources {
+		t.Run(filepath.Base(source), func(t *testing.T) {
+			src, err := os.ReadFile(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(source, ".asm"))
+			if err != nil {
+				t.Skip("no binary")
+			}
+			bin, err := assemble(string(src))
+			if err != nil {
+				t.Fatalf("Assemble() returned %v", err)
+			}
+			if !bytes.Equal(bin.Data, want) {
+				t.Errorf("Data.\ngot= % x\nwant=% x", bin.Data, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scope is what an expression is evaluated against: the address of the
+// statement it sits in ($), the start of the section ($$) and the symbols
+// known so far.
+type scope struct {
+	here   int
+	origin int
+	lookup func(name string) (int, bool)
+}
+
+// exprParser evaluates NASM integer expressions with the usual operators,
+// from lowest to highest precedence: | ^ & << >> + - * / % and the unary
+// - + ~ !, over numbers, character constants, symbols, $ and $$.
+type exprParser struct {
+	text  string
+	pos   int
+	scope scope
+}
+
+func evaluate(text string, s scope) (int, error) {
+	p := exprParser{text: text, scope: s}
+	v, err := p.binary(0)
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.text) {
+		return 0, fmt.Errorf("unexpected %q in expression %s", p.text[p.pos:], text)
+	}
+	return v, nil
+}
+
+// binaryOperators lists the operators of every precedence level, lowest
+// first.
+var binaryOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) binary(level int) (int, error) {
+	if level == len(binaryOperators) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpace()
+		op := ""
+		for _, candidate := range binaryOperators[level] {
+			if strings.HasPrefix(p.text[p.pos:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.pos += len(op)
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<":
+			left <<= uint(right)
+		case ">>":
+			left >>= uint(right)
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero in expression %s", p.text)
+			}
+			if op == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() (int, error) {
+	p.skipSpace()
+	if p.pos == len(p.text) {
+		return 0, fmt.Errorf("missing operand in expression %s", p.text)
+	}
+	switch p.text[p.pos] {
+	case '-', '+', '~', '!':
+		op := p.text[p.pos]
+		p.pos++
+		v, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '-':
+			return -v, nil
+		case '~':
+			return ^v, nil
+		case '!':
+			if v == 0 {
+				return 1, nil
+			}
+			return 0, nil
+		}
+		return v, nil
+	case '(':
+		p.pos++
+		v, err := p.binary(0)
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.text) || p.text[p.pos] != ')' {
+			return 0, fmt.Errorf("missing ) in expression %s", p.text)
+		}
+		p.pos++
+		return v, nil
+	case '\'', '"', '`':
+		quote := p.text[p.pos]
+		end := strings.IndexByte(p.text[p.pos+1:], quote)
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated string in expression %s", p.text)
+		}
+		literal := p.text[p.pos : p.pos+end+2]
+		p.pos += end + 2
+		v, ok := parseNumber(literal)
+		if !ok {
+			return 0, fmt.Errorf("invalid character constant %s", literal)
+		}
+		return v, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.text) && isSymbolChar(p.text[p.pos]) {
+		p.pos++
+	}
+	word := p.text[start:p.pos]
+	switch {
+	case word == "":
+		return 0, fmt.Errorf("unexpected %q in expression %s", p.text[p.pos:], p.text)
+	case word == "$":
+		return p.scope.here, nil
+	case word == "$$":
+		return p.scope.origin, nil
+	case word[0] >= '0' && word[0] <= '9':
+		v, ok := parseNumber(word)
+		if !ok {
+			return 0, fmt.Errorf("invalid number %s", word)
+		}
+		return v, nil
+	}
+	if p.scope.lookup != nil {
+		if v, ok := p.scope.lookup(word); ok {
+			return v, nil
+		}
+	}
+	return 0, &undefinedError{name: word}
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isSymbolChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.$@?#~", c) >= 0
+}
+
+// undefinedError is returned for a symbol that has not been defined, which
+// before the last pass only means that it has not been reached yet.
+type undefinedError struct {
+	name string
+}
+
+func (e *undefinedError) Error() string {
+	return fmt.Sprintf("undefined symbol %s", e.name)
+}
+
+// qualify rewrites the local labels (.name) referenced in text to their
+// full name, under the global label they follow.
+func qualify(text string, global string) string {
+	if global == "" || !strings.Contains(text, ".") {
+		return text
+	}
+	var out strings.Builder
+	quote := byte(0)
+	for n := 0; n < len(text); n++ {
+		c := text[n]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '.' && (n == 0 || !isSymbolChar(text[n-1])) && n+1 < len(text) && text[n+1] != '.' && isSymbolChar(text[n+1]):
+			out.WriteString(global)
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
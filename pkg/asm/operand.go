@@ -0,0 +1,387 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+// operand is a parsed operand along with the keywords written in front of
+// it, which only matter once the whole instruction is known.
+type operand struct {
+	instruction.InstructionOperand
+	// text is the operand as written, keywords left out
+	text string
+	// size is 8 or 16 when a byte or word keyword was given
+	size int
+	far  bool
+	near bool
+	// mode is the addressing mode picked for a memory operand
+	mode instruction.Mode
+}
+
+var sizeKeywords = map[string]bool{
+	"byte":  true,
+	"word":  true,
+	"far":   true,
+	"near":  true,
+	"short": true,
+}
+
+func parseOperand(text string) (operand, error) {
+	op := operand{}
+	for {
+		word, rest := nextWord(text)
+		keyword := strings.ToLower(word)
+		if !sizeKeywords[keyword] || rest == "" {
+			break
+		}
+		switch keyword {
+		case "byte":
+			op.size = 8
+		case "word":
+			op.size = 16
+		case "far":
+			op.far = true
+		case "near":
+			op.near = true
+		}
+		text = rest
+	}
+	op.text = text
+
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") {
+			return op, fmt.Errorf("unterminated memory operand %s", text)
+		}
+		mem, mode, err := parseMemory(text[1 : len(text)-1])
+		if err != nil {
+			return op, err
+		}
+		op.InstructionOperand, op.mode = mem, mode
+		return op, nil
+	}
+
+	if reg, ok := instruction.LookupRegister(text); ok {
+		op.Type = instruction.Operand_Register
+		op.Register = reg
+		return op, nil
+	}
+
+	if segment, offset, ok := strings.Cut(text, ":"); ok {
+		seg, okSeg := parseNumber(strings.TrimSpace(segment))
+		off, okOff := parseNumber(strings.TrimSpace(offset))
+		if !okSeg || !okOff {
+			return op, fmt.Errorf("invalid far pointer %s", text)
+		}
+		op.Type = instruction.Operand_FarPointer
+		op.FarPointer = instruction.FarPointer{SegmentValue: seg & 0xffff, OffsetValue: off & 0xffff}
+		return op, nil
+	}
+
+	if text == "" {
+		return op, fmt.Errorf("missing operand")
+	}
+	op.Type = instruction.Operand_Immediate
+	if v, ok := parseNumber(text); ok {
+		op.Value = v
+	} else {
+		op.Label = text
+	}
+	return op, nil
+}
+
+// parseMemory reads the inside of the brackets of a memory operand: an
+// optional segment override followed by base and index registers and
+// numbers, added or subtracted. When any other term names a symbol, the
+// terms that are not registers are kept as the DisplacementLabel to be
+// worked out once the program is laid out.
+func parseMemory(text string) (instruction.InstructionOperand, instruction.Mode, error) {
+	segment := instruction.Register{}
+	if prefix, rest, ok := strings.Cut(text, ":"); ok {
+		reg, ok := instruction.LookupRegister(strings.TrimSpace(prefix))
+		if !ok || reg.Class != instruction.Register_Segment {
+			return instruction.InstructionOperand{}, 0, fmt.Errorf("invalid segment override %s", prefix)
+		}
+		segment, text = reg, rest
+	}
+
+	terms := [2]string{}
+	count, disp := 0, 0
+	expr, symbolic := []string{}, false
+	for _, term := range splitTerms(text) {
+		name := strings.TrimSpace(term[1:])
+		if reg, ok := instruction.LookupRegister(name); ok {
+			switch {
+			case term[0] == '-':
+				return instruction.InstructionOperand{}, 0, fmt.Errorf("register %s can not be subtracted", name)
+			case count == len(terms):
+				return instruction.InstructionOperand{}, 0, fmt.Errorf("too many registers in [%s]", text)
+			}
+			terms[count] = reg.Name
+			count++
+			continue
+		}
+
+		if len(expr) == 0 && term[0] == '+' {
+			expr = append(expr, name)
+		} else if len(expr) == 0 {
+			expr = append(expr, "-"+name)
+		} else {
+			expr = append(expr, string(term[0]), name)
+		}
+		v, ok := parseNumber(name)
+		if !ok {
+			if name == "" || !isExpression(name) {
+				return instruction.InstructionOperand{}, 0, fmt.Errorf("invalid address term %s", name)
+			}
+			symbolic = true
+			continue
+		}
+		if term[0] == '-' {
+			v = -v
+		}
+		disp += v
+	}
+
+	if symbolic {
+		disp = 0
+	}
+	mem, mode, err := instruction.ResolveEffectiveAddress(terms, disp)
+	if err != nil {
+		return mem, mode, err
+	}
+	mem.Segment = segment
+	if symbolic {
+		mem.DisplacementLabel = strings.Join(expr, " ")
+	}
+	return mem, mode, nil
+}
+
+// isExpression tells whether text is made of the characters an expression
+// can be written with.
+func isExpression(text string) bool {
+	for n := 0; n < len(text); n++ {
+		if !isSymbolChar(text[n]) && strings.IndexByte(" \t*/%()<>&|^~!'\"`", text[n]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTerms splits an address expression on + and -, every term keeping
+// the sign in front of it.
+func splitTerms(text string) []string {
+	text = strings.TrimSpace(text)
+	terms := []string{}
+	sign, start := byte('+'), 0
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		sign, start = text[0], 1
+	}
+	for n := start; n < len(text); n++ {
+		if text[n] == '+' || text[n] == '-' {
+			terms = append(terms, string(sign)+text[start:n])
+			sign, start = text[n], n+1
+		}
+	}
+	return append(terms, string(sign)+text[start:])
+}
+
+// parseNumber reads a decimal, 0x or h suffixed hexadecimal, 0b binary or
+// quoted character literal, with an optional sign.
+func parseNumber(text string) (int, bool) {
+	negative := false
+	if text != "" && (text[0] == '-' || text[0] == '+') {
+		negative = text[0] == '-'
+		text = strings.TrimSpace(text[1:])
+	}
+	if text == "" {
+		return 0, false
+	}
+
+	var v int64
+	var err error
+	lower := strings.ToLower(text)
+	switch {
+	case len(text) >= 3 && (text[0] == '\'' || text[0] == '"' || text[0] == '`') && text[len(text)-1] == text[0]:
+		chars := text[1 : len(text)-1]
+		if len(chars) > 2 {
+			return 0, false
+		}
+		for n := len(chars) - 1; n >= 0; n-- {
+			v = v<<8 | int64(chars[n])
+		}
+	case strings.HasPrefix(lower, "0x"):
+		v, err = strconv.ParseInt(lower[2:], 16, 64)
+	case strings.HasPrefix(lower, "0b"):
+		v, err = strconv.ParseInt(lower[2:], 2, 64)
+	case strings.HasSuffix(lower, "h") && lower[0] >= '0' && lower[0] <= '9':
+		v, err = strconv.ParseInt(lower[:len(lower)-1], 16, 64)
+	default:
+		v, err = strconv.ParseInt(lower, 10, 64)
+	}
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		v = -v
+	}
+	return int(v), true
+}
+
+// isBranch tells whether op takes a relative target.
+func isBranch(op instruction.OperationType) bool {
+	switch op {
+	case instruction.Op_jmp, instruction.Op_call, instruction.Op_loop, instruction.Op_loopz, instruction.Op_loopnz, instruction.Op_jcxz,
+		instruction.Op_je, instruction.Op_jl, instruction.Op_jle, instruction.Op_jb, instruction.Op_jbe, instruction.Op_jp, instruction.Op_jo, instruction.Op_js,
+		instruction.Op_jne, instruction.Op_jnl, instruction.Op_jg, instruction.Op_jnb, instruction.Op_ja, instruction.Op_jnp, instruction.Op_jno, instruction.Op_jns:
+		return true
+	}
+	return false
+}
+
+// buildOperands places ops in the instruction, the first one in Reg and the
+// second in RM as the decoder does, and works out its width.
+func buildOperands(in *instruction.Instruction, ops []operand) error {
+	for n := range ops {
+		op := &ops[n]
+		if op.Type == instruction.Operand_Memory {
+			in.Mode = op.mode
+			if op.Segment.Name != "" {
+				in.Flags |= instruction.Inst_Segment
+				in.SegmentOverride = op.Segment
+			} else if in.Flags&instruction.Inst_Segment != 0 {
+				op.Segment = in.SegmentOverride
+			}
+		}
+		if op.far {
+			in.Flags |= instruction.Inst_Far
+		}
+		if op.Type == instruction.Operand_FarPointer {
+			in.Flags |= instruction.Inst_Far
+		}
+
+		if op.Type == instruction.Operand_Immediate && isBranch(in.Op) {
+			// the target is an address, resolved once the program is
+			// laid out
+			op.Immediate.Flags |= instruction.Immediate_RelativeJumpDisplacement
+			op.Label, op.Value = op.text, 0
+			if op.near {
+				op.Immediate.Flags |= int(instruction.Bits_W)
+			}
+		}
+	}
+
+	switch len(ops) {
+	case 2:
+		in.RM = ops[1].InstructionOperand
+		fallthrough
+	case 1:
+		in.Reg = ops[0].InstructionOperand
+	}
+
+	if in.IsString() || in.Op == instruction.Op_rep {
+		if len(ops) != 0 {
+			return fmt.Errorf("string instructions take no operands")
+		}
+		return nil
+	}
+
+	switch in.Op {
+	case instruction.Op_push, instruction.Op_pop:
+		in.Wide = true
+		return nil
+	case instruction.Op_jmp, instruction.Op_call:
+		in.Wide = len(ops) == 1 && ops[0].Type != instruction.Operand_Immediate
+		return nil
+	}
+
+	size := 0
+	for n, op := range ops {
+		width := 0
+		switch {
+		case op.Type == instruction.Operand_Register && isPort(in.Op, n, op):
+		case op.Type == instruction.Operand_Register && n == 1 && in.IsShift():
+		case op.Type == instruction.Operand_Register && op.Class == instruction.Register_Segment:
+			width = 16
+		case op.Type == instruction.Operand_Register:
+			width = 16
+			if strings.HasSuffix(op.Name, "L") || strings.HasSuffix(op.Name, "H") {
+				width = 8
+			}
+		default:
+			width = op.size
+		}
+		if width == 0 {
+			continue
+		}
+		if size != 0 && size != width {
+			return fmt.Errorf("operand size mismatch")
+		}
+		size = width
+	}
+
+	if size == 0 {
+		for _, op := range ops {
+			if op.Type == instruction.Operand_Memory {
+				return fmt.Errorf("operation size not specified")
+			}
+		}
+	}
+	in.Wide = size == 16
+
+	for _, op := range []*instruction.InstructionOperand{&in.Reg, &in.RM} {
+		if op.Type == instruction.Operand_Immediate && op.Label == "" {
+			if err := fitImmediate(in, &op.Immediate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fitImmediate gives imm the flags the decoder would and folds its value
+// into the same range, failing when it does not fit the field it goes in.
+func fitImmediate(in *instruction.Instruction, imm *instruction.Immediate) error {
+	bits := 8
+	switch in.Op {
+	case instruction.Op_in, instruction.Op_out, instruction.Op_int, instruction.Op_db:
+		imm.Flags |= instruction.Immediate_Unsigned
+	case instruction.Op_ret, instruction.Op_retf:
+		imm.Flags |= instruction.Immediate_Unsigned
+		bits = 16
+	default:
+		if in.IsShift() {
+			if imm.Value != 1 {
+				return fmt.Errorf("shift count must be 1 or cl")
+			}
+			return nil
+		}
+		if in.Wide {
+			bits = 16
+		}
+	}
+
+	if imm.Value < -1<<(bits-1) || imm.Value >= 1<<bits {
+		return fmt.Errorf("value %d does not fit in %d bits", imm.Value, bits)
+	}
+	if bits == 16 {
+		imm.Flags |= int(instruction.Bits_W)
+	}
+	switch {
+	case imm.Flags&instruction.Immediate_Unsigned != 0:
+		imm.Value &= 1<<bits - 1
+	case bits == 16:
+		imm.Value = int(int16(imm.Value))
+	default:
+		imm.Value = int(int8(imm.Value))
+	}
+	return nil
+}
+
+// isPort tells whether op is the DX port operand of an in or out.
+func isPort(o instruction.OperationType, n int, op operand) bool {
+	return op.Name == "DX" && ((o == instruction.Op_in && n == 1) || (o == instruction.Op_out && n == 0))
+}
@@ -0,0 +1,469 @@
+// Package asm reads the NASM dialect the disassembler writes, and that the
+// listings in part1/ are written in, back into instructions.
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+// Program is a parsed source file, one statement per instruction or
+// directive that lays out bytes.
+type Program struct {
+	Statements []Statement
+	// Labels maps every label to the index of the statement it precedes,
+	// len(Statements) for a label at the very end. Local labels (.name)
+	// are stored under the global label they follow, as global.name.
+	Labels map[string]int
+
+	// global is the last global label, the one local labels belong to
+	global string
+}
+
+type StatementKind int
+
+const (
+	Statement_Instruction StatementKind = iota
+	Statement_Data
+	Statement_Org
+	Statement_Align
+	Statement_Equ
+)
+
+// Statement is a single line of source. Nothing is resolved yet: the
+// instruction is laid out as the decoder would have produced it, but
+// Address, Size and Bytes are left empty, and any operand that names a
+// symbol rather than a number keeps the expression it was written with in
+// Immediate.Label or DisplacementLabel.
+type Statement struct {
+	Kind StatementKind
+	Line int
+
+	Instruction instruction.Instruction
+
+	// Width is 1 for db and 2 for dw, Items the values they list, either
+	// expressions or strings still in their quotes.
+	Width int
+	Items []string
+
+	// Name is the symbol an equ defines. Expr is the argument of equ, org
+	// and align, Fill the byte align pads with.
+	Name string
+	Expr string
+	Fill string
+
+	// Times is the count of a times prefix, empty when there is none.
+	Times string
+}
+
+// Instructions returns the instructions of p in source order, leaving the
+// directives out.
+func (p *Program) Instructions() []instruction.Instruction {
+	out := []instruction.Instruction{}
+	for _, s := range p.Statements {
+		if s.Kind == Statement_Instruction {
+			out = append(out, s.Instruction)
+		}
+	}
+	return out
+}
+
+// aliases are the alternative NASM spellings of mnemonics the decoder
+// only knows under one name. nop is xchg ax, ax, see parseInstruction.
+var aliases = map[string]instruction.OperationType{
+	"nop":    instruction.Op_xchg,
+	"jz":     instruction.Op_je,
+	"jnz":    instruction.Op_jne,
+	"jnge":   instruction.Op_jl,
+	"jge":    instruction.Op_jnl,
+	"jng":    instruction.Op_jle,
+	"jnle":   instruction.Op_jg,
+	"jc":     instruction.Op_jb,
+	"jnae":   instruction.Op_jb,
+	"jnc":    instruction.Op_jnb,
+	"jae":    instruction.Op_jnb,
+	"jna":    instruction.Op_jbe,
+	"jnbe":   instruction.Op_ja,
+	"jpe":    instruction.Op_jp,
+	"jpo":    instruction.Op_jnp,
+	"loope":  instruction.Op_loopz,
+	"loopne": instruction.Op_loopnz,
+	"sal":    instruction.Op_shl,
+	"xlatb":  instruction.Op_xlat,
+}
+
+// prefixes are the words that may precede a mnemonic, and the flags they
+// set on the instruction.
+var prefixes = map[string]int{
+	"lock":  instruction.Inst_Lock,
+	"rep":   instruction.Inst_Rep,
+	"repe":  instruction.Inst_Rep,
+	"repz":  instruction.Inst_Rep,
+	"repne": instruction.Inst_RepNE,
+	"repnz": instruction.Inst_RepNE,
+}
+
+// Parse reads a whole source file. Errors carry the line they were found on.
+func Parse(r io.Reader) (*Program, error) {
+	prog := &Program{Labels: map[string]int{}}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := prog.parseLine(scanner.Text(), line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+func (p *Program) parseLine(text string, line int) error {
+	text = strings.TrimSpace(stripComment(text))
+
+	// a label ends with a colon and may share its line with an instruction
+	if n := strings.IndexByte(text, ':'); n > 0 && isLabel(text[:n]) {
+		name, rest := text[:n], strings.TrimSpace(text[n+1:])
+		if word, _ := nextWord(rest); strings.EqualFold(word, "equ") {
+			text = name + " " + rest
+		} else {
+			if err := p.defineLabel(name); err != nil {
+				return err
+			}
+			text = rest
+		}
+	}
+	if text == "" {
+		return nil
+	}
+
+	// data and constants may be named without the colon
+	first, rest := nextWord(text)
+	second, expr := nextWord(rest)
+	switch strings.ToLower(second) {
+	case "equ":
+		if !isLabel(first) || expr == "" {
+			return fmt.Errorf("equ needs a name and a value")
+		}
+		p.Statements = append(p.Statements, Statement{Kind: Statement_Equ, Line: line, Name: p.qualifyName(first), Expr: qualify(expr, p.global)})
+		return nil
+	case "db", "dw", "times":
+		if isLabel(first) && !isKeyword(first) {
+			if err := p.defineLabel(first); err != nil {
+				return err
+			}
+			text = rest
+		}
+	}
+
+	s, err := p.parseStatement(text)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return nil
+	}
+	s.Line = line
+	p.Statements = append(p.Statements, *s)
+	return nil
+}
+
+// parseStatement reads a directive or an instruction, nil being returned
+// for a directive that lays out nothing.
+func (p *Program) parseStatement(text string) (*Statement, error) {
+	directive, rest := nextWord(text)
+	switch strings.ToLower(directive) {
+	case "bits":
+		if rest != "16" {
+			return nil, fmt.Errorf("only bits 16 is supported")
+		}
+		return nil, nil
+	case "org":
+		if rest == "" {
+			return nil, fmt.Errorf("org without an address")
+		}
+		return &Statement{Kind: Statement_Org, Expr: qualify(rest, p.global)}, nil
+	case "align":
+		args := splitOperands(qualify(rest, p.global))
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("align takes a boundary and an optional db fill")
+		}
+		s := &Statement{Kind: Statement_Align, Expr: args[0], Fill: "0x90"}
+		if len(args) == 2 {
+			fill, value := nextWord(args[1])
+			if !strings.EqualFold(fill, "db") || value == "" {
+				return nil, fmt.Errorf("align fill must be written as db value")
+			}
+			s.Fill = value
+		}
+		return s, nil
+	case "db", "dw":
+		items := splitOperands(qualify(rest, p.global))
+		if len(items) == 0 {
+			return nil, fmt.Errorf("%s without values", directive)
+		}
+		width := 1
+		if strings.EqualFold(directive, "dw") {
+			width = 2
+		}
+		return &Statement{Kind: Statement_Data, Width: width, Items: items}, nil
+	case "times":
+		count, repeated, ok := splitTimes(rest)
+		if !ok {
+			// say what is wrong with the mnemonic rather than that there
+			// is none
+			if repeated != "" {
+				if _, err := parseInstruction(repeated); err != nil {
+					return nil, err
+				}
+			}
+			return nil, fmt.Errorf("times without an instruction or data to repeat")
+		}
+		s, err := p.parseStatement(repeated)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil || (s.Kind != Statement_Instruction && s.Kind != Statement_Data) || s.Times != "" {
+			return nil, fmt.Errorf("times can only repeat an instruction or data")
+		}
+		s.Times = qualify(count, p.global)
+		return s, nil
+	}
+
+	in, err := parseInstruction(qualify(text, p.global))
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{Kind: Statement_Instruction, Instruction: in}, nil
+}
+
+// splitTimes splits the argument of times into the count expression and
+// the statement it repeats, which starts at the first word that reads as
+// data or an instruction. When there is none, the statement returned is
+// the one starting at the first word that looks like a mnemonic, if any.
+func splitTimes(text string) (string, string, bool) {
+	guess := ""
+	for n := 0; n < len(text); n++ {
+		if text[n] != ' ' && text[n] != '\t' {
+			continue
+		}
+		count, repeated := strings.TrimSpace(text[:n]), strings.TrimSpace(text[n:])
+		if count == "" || repeated == "" {
+			continue
+		}
+		if isKeyword(repeated) {
+			return count, repeated, true
+		}
+		if c := repeated[0] | 0x20; guess == "" && c >= 'a' && c <= 'z' {
+			guess = repeated
+		}
+	}
+	return "", guess, false
+}
+
+// isKeyword tells whether text starts with a directive, prefix or mnemonic.
+func isKeyword(text string) bool {
+	word, _ := nextWord(text)
+	word = strings.ToLower(word)
+	switch word {
+	case "db", "dw", "times", "equ", "org", "align", "bits":
+		return true
+	}
+	if _, ok := prefixes[word]; ok {
+		return true
+	}
+	in := instruction.Instruction{}
+	return setOperation(&in, word) == nil
+}
+
+func (p *Program) defineLabel(name string) error {
+	if !strings.HasPrefix(name, ".") {
+		p.global = name
+	}
+	name = p.qualifyName(name)
+	if _, ok := p.Labels[name]; ok {
+		return fmt.Errorf("label %s redefined", name)
+	}
+	p.Labels[name] = len(p.Statements)
+	return nil
+}
+
+// qualifyName returns the full name of a label defined here.
+func (p *Program) qualifyName(name string) string {
+	if strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "..") {
+		return p.global + name
+	}
+	return name
+}
+
+// parseInstruction reads the prefixes, the mnemonic and the operands of a
+// single instruction.
+func parseInstruction(text string) (instruction.Instruction, error) {
+	in := instruction.Instruction{}
+
+	mnemonic, rest := nextWord(text)
+	for {
+		word := strings.ToLower(mnemonic)
+		next, after := nextWord(rest)
+		if next == "" {
+			break
+		}
+		if flag, ok := prefixes[word]; ok {
+			in.Flags |= flag
+		} else if reg, ok := instruction.LookupRegister(word); ok && reg.Class == instruction.Register_Segment {
+			in.Flags |= instruction.Inst_Segment
+			in.SegmentOverride = reg
+		} else {
+			break
+		}
+		mnemonic, rest = next, after
+	}
+
+	if err := setOperation(&in, strings.ToLower(mnemonic)); err != nil {
+		return in, err
+	}
+
+	texts := splitOperands(rest)
+	if strings.EqualFold(mnemonic, "nop") {
+		if len(texts) != 0 {
+			return in, fmt.Errorf("nop takes no operands")
+		}
+		texts = []string{"ax", "ax"}
+	}
+	// a segment prefix on its own line, which a following word would have
+	// taken as an override, is encoded from its register
+	if in.Op == instruction.Op_segment {
+		texts = []string{mnemonic}
+	}
+	if len(texts) > 2 {
+		return in, fmt.Errorf("%s: too many operands", mnemonic)
+	}
+	ops := make([]operand, len(texts))
+	for n, t := range texts {
+		op, err := parseOperand(t)
+		if err != nil {
+			return in, err
+		}
+		ops[n] = op
+	}
+
+	if err := buildOperands(&in, ops); err != nil {
+		return in, fmt.Errorf("%s: %w", mnemonic, err)
+	}
+	return in, nil
+}
+
+// setOperation resolves mnemonic, including the b/w suffixed string
+// instructions and a rep or segment prefix written on its own.
+func setOperation(in *instruction.Instruction, mnemonic string) error {
+	if flag, ok := prefixes[mnemonic]; ok && flag != instruction.Inst_Lock {
+		in.Op = instruction.Op_rep
+		in.Flags |= flag
+		return nil
+	}
+	if reg, ok := instruction.LookupRegister(mnemonic); ok && reg.Class == instruction.Register_Segment {
+		in.Op = instruction.Op_segment
+		return nil
+	}
+	if op, ok := aliases[mnemonic]; ok {
+		in.Op = op
+		return nil
+	}
+	if n := len(mnemonic) - 1; n > 0 && (mnemonic[n] == 'b' || mnemonic[n] == 'w') {
+		if op, ok := instruction.LookupOperation(mnemonic[:n]); ok {
+			in.Op = op
+			if in.IsString() {
+				in.Wide = mnemonic[n] == 'w'
+				return nil
+			}
+		}
+	}
+	op, ok := instruction.LookupOperation(mnemonic)
+	if !ok || op == instruction.Op_movs || op == instruction.Op_cmps || op == instruction.Op_scas || op == instruction.Op_lods || op == instruction.Op_stos {
+		return fmt.Errorf("unknown instruction %q", mnemonic)
+	}
+	in.Op = op
+	return nil
+}
+
+// stripComment cuts text at the first semicolon outside a string.
+func stripComment(text string) string {
+	quote := byte(0)
+	for n := 0; n < len(text); n++ {
+		switch c := text[n]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';':
+			return text[:n]
+		}
+	}
+	return text
+}
+
+// nextWord splits the first whitespace separated word off text.
+func nextWord(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	n := strings.IndexAny(text, " \t")
+	if n < 0 {
+		return text, ""
+	}
+	return text[:n], strings.TrimSpace(text[n:])
+}
+
+// splitOperands splits text on the commas outside brackets and strings.
+func splitOperands(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	out := []string{}
+	depth, quote, start := 0, byte(0), 0
+	for n := 0; n < len(text); n++ {
+		switch c := text[n]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(text[start:n]))
+			start = n + 1
+		}
+	}
+	return append(out, strings.TrimSpace(text[start:]))
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') || s == "$" || s == "$$" {
+		return false
+	}
+	for n := 0; n < len(s); n++ {
+		if !isSymbolChar(s[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLabel tells whether s can name a label, registers and keywords can not.
+func isLabel(s string) bool {
+	if _, ok := instruction.LookupRegister(s); ok {
+		return false
+	}
+	return isIdentifier(s) && !sizeKeywords[strings.ToLower(s)]
+}
@@ -0,0 +1,186 @@
+package asm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/asm"
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		wide bool
+	}{
+		{"mov cx, bx", "mov cx, bx", true},
+		{"mov dh, [bx + di - 37]", "mov dh, [bx + di - 37]", false},
+		{"mov [si+bp], cl", "mov [bp + si], cl", false},
+		{"mov ax, [bp]", "mov ax, [bp]", true},
+		{"mov ax, [bp + 0]", "mov ax, [bp]", true},
+		{"mov bx, [0x10]", "mov bx, [16]", true},
+		{"mov [bp + di], byte 7", "mov [bp + di], byte 7", false},
+		{"add word [bp + si + 1000], 29", "add word [bp + si + 1000], 29", true},
+		{"MOV CH, -12", "mov ch, -12", false},
+		{"mov al, 0ffh", "mov al, -1", false},
+		{"mov al, 'A'", "mov al, 65", false},
+		{"mov ax, [es:bx + 4]", "mov ax, [es:bx + 4]", true},
+		{"es mov ax, [bx]", "mov ax, [es:bx]", true},
+		{"mov ds, bx", "mov ds, bx", true},
+		{"nop", "xchg ax, ax", true},
+		{"es", "es", true},
+		{"times 3 nop", "xchg ax, ax", true},
+		{"lock xchg [bx], ax", "lock xchg [bx], ax", true},
+		{"rep movsw", "rep movsw", true},
+		{"repe cmpsb", "repe cmpsb", false},
+		{"repnz scasb", "repne scasb", false},
+		{"shl word [bx], 1", "shl word [bx], 1", true},
+		{"sar bl, cl", "sar bl, cl", false},
+		{"in al, dx", "in al, dx", false},
+		{"out dx, ax", "out dx, ax", true},
+		{"in ax, 0x60", "in ax, 96", true},
+		{"push word [bp + 4]", "push word [bp + 4]", true},
+		{"pop es", "pop es", true},
+		{"call far [bx]", "call far [bx]", true},
+		{"jmp 0x1234:0x5678", "jmp 0x1234:0x5678", true},
+		{"jnz top", "jne top", false},
+		{"jmp near $+4", "jmp near $+4", false},
+		{"mov dx, 0x8888", "mov dx, -30584", true},
+		{"in al, 0xff", "in al, 255", false},
+		{"loop .back", "loop .back", false},
+		{"int 21h", "int 33", false},
+		{"ret 4", "ret 4", false},
+		{"xlatb", "xlat", false},
+		{"cbw", "cbw", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			prog, err := asm.Parse(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatalf("Parse() returned %v", err)
+			}
+			instructions := prog.Instructions()
+			if len(instructions) != 1 {
+				t.Fatalf("Parse() returned %d instructions, want 1", len(instructions))
+			}
+			in := instructions[0]
+			if in.String() != tt.want {
+				t.Errorf("String(). got=%s want=%s", in.String(), tt.want)
+			}
+			if in.Wide != tt.wide {
+				t.Errorf("Wide. got=%v want=%v", in.Wide, tt.wide)
+			}
+		})
+	}
+}
+
+func TestParse_Labels(t *testing.T) {
+	src := `bits 16
+; counts down
+start:
+	mov cx, 3 ; three times
+loop_top: dec cx
+	jnz loop_top
+	jmp start
+end:
+`
+	prog, err := asm.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() returned %v", err)
+	}
+	if len(prog.Statements) != 4 {
+		t.Fatalf("Parse() returned %d statements, want 4", len(prog.Statements))
+	}
+	want := map[string]int{"start": 0, "loop_top": 1, "end": 4}
+	for name, n := range want {
+		if got, ok := prog.Labels[name]; !ok || got != n {
+			t.Errorf("Labels[%s]. got=%d,%v want=%d", name, got, ok, n)
+		}
+	}
+	for n, line := range []int{4, 5, 6, 7} {
+		if got := prog.Statements[n].Line; got != line {
+			t.Errorf("Statements[%d].Line. got=%d want=%d", n, got, line)
+		}
+	}
+	jnz := prog.Statements[2].Instruction.Reg
+	if jnz.Label != "loop_top" || jnz.Immediate.Flags&instruction.Immediate_RelativeJumpDisplacement == 0 {
+		t.Errorf("jnz target. got=%+v", jnz.Immediate)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"bits 32", "line 1: only bits 16 is supported"},
+		{"cbw\nmovx ax, bx", "line 2: unknown instruction \"movx\""},
+		{"mov al, 256", "line 1: mov: value 256 does not fit in 8 bits"},
+		{"shl ax, 2", "line 1: shl: shift count must be 1 or cl"},
+		{"mov [bx], 5", "line 1: mov: operation size not specified"},
+		{"mov ax, bl", "line 1: mov: operand size mismatch"},
+		{"mov ax, [bx + cx]", "line 1: invalid effective address [BX + CX]"},
+		{"mov ax, [bx + si + di]", "line 1: too many registers in [bx + si + di]"},
+		{"mov ax, [bx", "line 1: unterminated memory operand [bx"},
+		{"a:\na: cbw", "line 2: label a redefined"},
+		{"add ax, 1, 2", "line 1: add: too many operands"},
+		{"movsb al", "line 1: movsb: string instructions take no operands"},
+		{"nop ax", "line 1: nop takes no operands"},
+		{"times 3 nopp", "line 1: unknown instruction \"nopp\""},
+		{"times 3", "line 1: times without an instruction or data to repeat"},
+	}
+	for _, tt := range tests {
+		_, err := asm.Parse(strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q) error. got=%v want=%s", tt.src, err, tt.err)
+		}
+	}
+}
+
+// TestParse_Listings parses the part1 sources and checks that they read
+// back into what the decoder makes of the assembled binaries. Jump targets
+// are written as labels in the source, so only their operation is compared.
+func TestParse_Listings(t *testing.T) {
+	sources, _ := filepath.Glob("../../part1/listing_*.asm")
+	if len(sources) == 0 {
+		t.Skip("no listings")
+	}
+	for _, source := range sources {
+		t.Run(filepath.Base(source), func(t *testing.T) {
+			f, err := os.Open(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			prog, err := asm.Parse(f)
+			if err != nil {
+				t.Fatalf("Parse() returned %v", err)
+			}
+
+			r, err := reader.New(strings.TrimSuffix(source, ".asm"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			l := lexer.New(r)
+			for n, in := range prog.Instructions() {
+				got := l.NextInstruction()
+				if in.Op != got.Op {
+					t.Fatalf("instruction %d. parsed=%s decoded=%s", n, in, got)
+				}
+				if _, ok := got.BranchTarget(); !ok && in.String() != got.String() {
+					t.Errorf("instruction %d. parsed=%s decoded=%s", n, in, got)
+				}
+				if in.Wide != got.Wide && !got.IsString() {
+					t.Errorf("instruction %d %s. parsed wide=%v decoded wide=%v", n, in, in.Wide, got.Wide)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package cfg splits a decoded instruction stream into basic blocks and
+// connects them into a control-flow graph.
+package cfg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+type EdgeKind int
+
+const (
+	Edge_FallThrough EdgeKind = iota
+	Edge_Taken
+	Edge_Call
+	Edge_Return
+)
+
+func (k EdgeKind) String() string {
+	switch k {
+	case Edge_Taken:
+		return "taken"
+	case Edge_Call:
+		return "call"
+	case Edge_Return:
+		return "return"
+	default:
+		return "fallthrough"
+	}
+}
+
+// Block is a run of instructions that is only entered at its first one and
+// only left after its last one. Start and End are addresses, End being the
+// first one past the block.
+type Block struct {
+	ID           int
+	Start        int
+	End          int
+	Instructions []instruction.Instruction
+}
+
+// Last returns the instruction that ends the block.
+func (b *Block) Last() instruction.Instruction {
+	return b.Instructions[len(b.Instructions)-1]
+}
+
+// Edge connects two blocks by their ID.
+type Edge struct {
+	From int
+	To   int
+	Kind EdgeKind
+}
+
+type Graph struct {
+	// Blocks are in address order, a block's ID is its index.
+	Blocks []*Block
+	Edges  []Edge
+
+	byStart map[int]*Block
+}
+
+// Build splits instrs, in address order, into basic blocks. A block starts
+// at the first instruction, at every branch target and after every branch,
+// and data (Op_db) is left out. Return edges go from every block ending in
+// a ret to the instruction after each call that reaches it.
+func Build(instrs []instruction.Instruction) *Graph {
+	g := &Graph{byStart: map[int]*Block{}}
+
+	leaders := map[int]bool{}
+	for n, in := range instrs {
+		if in.Op == instruction.Op_db {
+			continue
+		}
+		if n == 0 || instrs[n-1].Op == instruction.Op_db || instrs[n-1].Address+instrs[n-1].Size != in.Address {
+			leaders[in.Address] = true
+		}
+		if target, ok := in.BranchTarget(); ok {
+			leaders[target] = true
+		}
+		if in.Semantics().Branch != instruction.Branch_None || in.Op == instruction.Op_hlt {
+			leaders[in.Address+in.Size] = true
+		}
+	}
+
+	var current *Block
+	for _, in := range instrs {
+		if in.Op == instruction.Op_db {
+			current = nil
+			continue
+		}
+		if current == nil || leaders[in.Address] {
+			current = &Block{ID: len(g.Blocks), Start: in.Address}
+			g.Blocks = append(g.Blocks, current)
+			g.byStart[in.Address] = current
+		}
+		current.Instructions = append(current.Instructions, in)
+		current.End = in.Address + in.Size
+	}
+
+	// return sites of every call, by the block called
+	returnSites := map[int][]int{}
+
+	for _, b := range g.Blocks {
+		last := b.Last()
+		kind := last.Semantics().Branch
+
+		if target, ok := last.BranchTarget(); ok {
+			if to, ok := g.byStart[target]; ok {
+				edge := Edge_Taken
+				if kind == instruction.Branch_Call {
+					edge = Edge_Call
+				}
+				g.Edges = append(g.Edges, Edge{From: b.ID, To: to.ID, Kind: edge})
+				if kind == instruction.Branch_Call {
+					if next, ok := g.byStart[b.End]; ok {
+						returnSites[to.ID] = append(returnSites[to.ID], next.ID)
+					}
+				}
+			}
+		}
+
+		switch kind {
+		case instruction.Branch_Unconditional, instruction.Branch_Ret:
+			continue
+		}
+		if last.Op == instruction.Op_hlt {
+			continue
+		}
+		if next, ok := g.byStart[b.End]; ok {
+			g.Edges = append(g.Edges, Edge{From: b.ID, To: next.ID, Kind: Edge_FallThrough})
+		}
+	}
+
+	calls := make([]int, 0, len(returnSites))
+	for entry := range returnSites {
+		calls = append(calls, entry)
+	}
+	sort.Ints(calls)
+	for _, entry := range calls {
+		for _, ret := range g.returns(entry) {
+			for _, site := range returnSites[entry] {
+				g.Edges = append(g.Edges, Edge{From: ret, To: site, Kind: Edge_Return})
+			}
+		}
+	}
+
+	return g
+}
+
+// returns lists the blocks ending in a ret that can be reached from entry
+// without following calls, in address order.
+func (g *Graph) returns(entry int) []int {
+	seen := map[int]bool{entry: true}
+	work := []int{entry}
+	rets := []int{}
+	for len(work) > 0 {
+		id := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if g.Blocks[id].Last().Semantics().Branch == instruction.Branch_Ret {
+			rets = append(rets, id)
+		}
+		for _, e := range g.Edges {
+			if e.From != id || seen[e.To] || (e.Kind != Edge_FallThrough && e.Kind != Edge_Taken) {
+				continue
+			}
+			seen[e.To] = true
+			work = append(work, e.To)
+		}
+	}
+	sort.Ints(rets)
+	return rets
+}
+
+// BlockAt returns the block that starts at address.
+func (g *Graph) BlockAt(address int) (*Block, bool) {
+	b, ok := g.byStart[address]
+	return b, ok
+}
+
+// Successors returns the edges leaving block id.
+func (g *Graph) Successors(id int) []Edge {
+	edges := []Edge{}
+	for _, e := range g.Edges {
+		if e.From == id {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+var edgeStyles = map[EdgeKind]string{
+	Edge_FallThrough: "",
+	Edge_Taken:       ` [label="taken", color="darkgreen"]`,
+	Edge_Call:        ` [label="call", style="dashed", color="blue"]`,
+	Edge_Return:      ` [label="return", style="dotted", color="gray40"]`,
+}
+
+// WriteDOT writes the graph in Graphviz DOT, one box per block listing its
+// instructions.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var out strings.Builder
+
+	out.WriteString("digraph cfg {\n")
+	out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, b := range g.Blocks {
+		var label strings.Builder
+		for _, in := range b.Instructions {
+			label.WriteString(fmt.Sprintf("%04x  %s\\l", in.Address, escapeDOT(in.String())))
+		}
+		out.WriteString(fmt.Sprintf("\tb%d [label=\"%s\"];\n", b.ID, label.String()))
+	}
+	for _, e := range g.Edges {
+		out.WriteString(fmt.Sprintf("\tb%d -> b%d%s;\n", e.From, e.To, edgeStyles[e.Kind]))
+	}
+	out.WriteString("}\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func escapeDOT(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,89 @@
+package cfg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/cfg"
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+func decode(t *testing.T, data []byte) []instruction.Instruction {
+	t.Helper()
+	instrs, err := lexer.New(reader.NewFromBytes(data)).All()
+	if err != nil {
+		t.Fatalf("decoding % x: %v", data, err)
+	}
+	return instrs
+}
+
+func TestBuild(t *testing.T) {
+	g := cfg.Build(decode(t, []byte{
+		0xb9, 0x03, 0x00, // mov cx, 3
+		0xe8, 0x03, 0x00, // call 0x09
+		0xe2, 0xfb, // loop 0x03
+		0xf4,       // hlt
+		0x75, 0x01, // jne 0x0c
+		0x40, // inc ax
+		0xc3, // ret
+	}))
+
+	starts := []int{0x00, 0x03, 0x06, 0x08, 0x09, 0x0b, 0x0c}
+	if len(g.Blocks) != len(starts) {
+		t.Fatalf("got %d blocks want %d", len(g.Blocks), len(starts))
+	}
+	for n, b := range g.Blocks {
+		if b.ID != n || b.Start != starts[n] {
+			t.Errorf("block %d. got id=%d start=0x%02x want start=0x%02x", n, b.ID, b.Start, starts[n])
+		}
+	}
+
+	want := []cfg.Edge{
+		{From: 0, To: 1, Kind: cfg.Edge_FallThrough},
+		{From: 1, To: 4, Kind: cfg.Edge_Call},
+		{From: 1, To: 2, Kind: cfg.Edge_FallThrough},
+		{From: 2, To: 1, Kind: cfg.Edge_Taken},
+		{From: 2, To: 3, Kind: cfg.Edge_FallThrough},
+		{From: 4, To: 6, Kind: cfg.Edge_Taken},
+		{From: 4, To: 5, Kind: cfg.Edge_FallThrough},
+		{From: 5, To: 6, Kind: cfg.Edge_FallThrough},
+		{From: 6, To: 2, Kind: cfg.Edge_Return},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("edges. got=%v want=%v", g.Edges, want)
+	}
+	for n, e := range g.Edges {
+		if e != want[n] {
+			t.Errorf("edge %d. got=%v want=%v", n, e, want[n])
+		}
+	}
+
+	var dot strings.Builder
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatalf("WriteDOT: %v", err)
+	}
+	for _, line := range []string{
+		`	b4 [label="0009  jne $+3\l"];`,
+		`	b1 -> b4 [label="call", style="dashed", color="blue"];`,
+		`	b6 -> b2 [label="return", style="dotted", color="gray40"];`,
+	} {
+		if !strings.Contains(dot.String(), line+"\n") {
+			t.Errorf("WriteDOT() missing %q in\n%s", line, dot.String())
+		}
+	}
+}
+
+func TestBuild_SkipsData(t *testing.T) {
+	instrs := decode(t, []byte{0xeb, 0x01, 0xc3, 0x40})
+	instrs[1] = instruction.Instruction{Op: instruction.Op_db, Address: 2, Size: 1}
+
+	g := cfg.Build(instrs)
+	if len(g.Blocks) != 2 || g.Blocks[1].Start != 3 {
+		t.Fatalf("blocks. got=%d want 2 starting at 0 and 3", len(g.Blocks))
+	}
+	if len(g.Edges) != 1 || g.Edges[0] != (cfg.Edge{From: 0, To: 1, Kind: cfg.Edge_Taken}) {
+		t.Errorf("edges. got=%v", g.Edges)
+	}
+}
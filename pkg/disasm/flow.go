@@ -0,0 +1,139 @@
+package disasm
+
+import (
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+type RegionKind int
+
+const (
+	Region_Code RegionKind = iota
+	Region_Data
+)
+
+func (k RegionKind) String() string {
+	if k == Region_Code {
+		return "code"
+	}
+	return "data"
+}
+
+// Region is a run of bytes classified the same way, from Start up to but
+// not including End. Both are addresses, so they include the origin.
+type Region struct {
+	Start int
+	End   int
+	Kind  RegionKind
+}
+
+// Traversal is the result of a flow-following disassembly. Instructions
+// covers the whole input in address order, with every byte that no path
+// reached emitted as an Op_db.
+type Traversal struct {
+	Instructions []instruction.Instruction
+	Regions      []Region
+}
+
+const (
+	byteUnknown = iota
+	byteCodeStart
+	byteCode
+)
+
+// Traverse disassembles by following control flow from the given entry
+// points (addresses, origin included) instead of sweeping linearly. Paths
+// end at unconditional transfers, returns, hlt, undecodable bytes and
+// instructions that would overlap code found earlier. Indirect and far
+// targets are not followed.
+func Traverse(r *reader.Reader, entries []int) Traversal {
+	table := instruction.New8086InstructionTable()
+
+	state := make([]byte, len(r.Data))
+	decoded := map[int]instruction.Instruction{}
+
+	work := make([]int, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		work = append(work, entries[i]-r.Origin)
+	}
+
+	for len(work) > 0 {
+		offset := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for offset >= 0 && offset < len(r.Data) && state[offset] == byteUnknown {
+			if err := r.Seek(offset); err != nil {
+				break
+			}
+			in, err := table.DecodeInstruction(r)
+			if err != nil || overlaps(state, offset, in.Size) {
+				break
+			}
+
+			decoded[offset] = in
+			state[offset] = byteCodeStart
+			for i := offset + 1; i < offset+in.Size; i++ {
+				state[i] = byteCode
+			}
+
+			if target, ok := in.BranchTarget(); ok {
+				work = append(work, target-r.Origin)
+			}
+			if endsFlow(in) {
+				break
+			}
+			offset += in.Size
+		}
+	}
+
+	t := Traversal{}
+	for offset := 0; offset < len(r.Data); {
+		kind := Region_Data
+		if in, ok := decoded[offset]; ok {
+			t.Instructions = append(t.Instructions, in)
+			kind = Region_Code
+			offset += in.Size
+		} else {
+			t.Instructions = append(t.Instructions, instruction.Instruction{
+				Op:      instruction.Op_db,
+				Reg:     instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: int(r.Data[offset]), Flags: instruction.Immediate_Unsigned}},
+				Size:    1,
+				Address: r.Origin + offset,
+				Bytes:   r.Data[offset : offset+1 : offset+1],
+			})
+			offset++
+		}
+
+		last := len(t.Regions) - 1
+		if last >= 0 && t.Regions[last].Kind == kind {
+			t.Regions[last].End = r.Origin + offset
+		} else {
+			start := t.Instructions[len(t.Instructions)-1].Address
+			t.Regions = append(t.Regions, Region{Start: start, End: r.Origin + offset, Kind: kind})
+		}
+	}
+	return t
+}
+
+func overlaps(state []byte, offset int, size int) bool {
+	if offset+size > len(state) {
+		return true
+	}
+	for i := offset; i < offset+size; i++ {
+		if state[i] != byteUnknown {
+			return true
+		}
+	}
+	return false
+}
+
+// endsFlow tells whether execution never falls through to the next
+// instruction.
+func endsFlow(in instruction.Instruction) bool {
+	switch in.Semantics().Branch {
+	case instruction.Branch_Unconditional, instruction.Branch_Ret:
+		return true
+	default:
+		return in.Op == instruction.Op_hlt
+	}
+}
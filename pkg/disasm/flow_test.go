@@ -0,0 +1,61 @@
+package disasm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/disasm"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+func TestTraverse(t *testing.T) {
+	r := reader.NewFromBytes([]byte{
+		0xe8, 0x05, 0x00, // call 0x108
+		0xeb, 0x06, // jmp 0x10b
+		0x48, 0x69, 0x00, // "Hi\0"
+		0xb0, 0x01, // mov al, 1
+		0xc3,       // ret
+		0x74, 0x01, // je 0x10e
+		0xf4,       // hlt
+		0xcd, 0x20, // int 0x20, only reached through the je
+		0xff, // never reached
+	})
+	r.Origin = 0x100
+
+	tr := disasm.Traverse(r, []int{0x100})
+
+	var out strings.Builder
+	for _, in := range tr.Instructions {
+		out.WriteString(in.String() + "\n")
+	}
+	want := `call $+8
+jmp $+8
+db 0x48
+db 0x69
+db 0x00
+mov al, 1
+ret
+je $+3
+hlt
+int 32
+db 0xff
+`
+	if out.String() != want {
+		t.Errorf("instructions. got=\n%s\nwant=\n%s", out.String(), want)
+	}
+
+	wantRegions := []disasm.Region{
+		{Start: 0x100, End: 0x105, Kind: disasm.Region_Code},
+		{Start: 0x105, End: 0x108, Kind: disasm.Region_Data},
+		{Start: 0x108, End: 0x110, Kind: disasm.Region_Code},
+		{Start: 0x110, End: 0x111, Kind: disasm.Region_Data},
+	}
+	if len(tr.Regions) != len(wantRegions) {
+		t.Fatalf("regions. got=%+v want=%+v", tr.Regions, wantRegions)
+	}
+	for i, region := range tr.Regions {
+		if region != wantRegions[i] {
+			t.Errorf("region %d. got=%+v want=%+v", i, region, wantRegions[i])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+// Labels is the first of the two passes of a labelled disassembly: it names
+// every jump, call and loop target that starts one of the decoded
+// instructions, label_0 being the lowest address. Targets that land in the
+// middle of an instruction or outside the stream are left out, those keep
+// printing as $+N.
+func Labels(instrs []instruction.Instruction) map[int]string {
+	starts := make(map[int]bool, len(instrs))
+	for _, in := range instrs {
+		starts[in.Address] = true
+	}
+
+	targets := []int{}
+	seen := map[int]bool{}
+	for _, in := range instrs {
+		target, ok := in.BranchTarget()
+		if !ok || !starts[target] || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+
+	labels := make(map[int]string, len(targets))
+	for n, target := range targets {
+		labels[target] = fmt.Sprintf("label_%d", n)
+	}
+	return labels
+}
+
+// ApplyLabel returns in with its branch target printed as the label found
+// at that address, if there is one.
+func ApplyLabel(in instruction.Instruction, labels map[int]string) instruction.Instruction {
+	target, ok := in.BranchTarget()
+	if !ok {
+		return in
+	}
+	label, ok := labels[target]
+	if !ok {
+		return in
+	}
+
+	if in.Reg.Type == instruction.Operand_Immediate {
+		in.Reg.Label = label
+	}
+	if in.RM.Type == instruction.Operand_Immediate {
+		in.RM.Label = label
+	}
+	return in
+}
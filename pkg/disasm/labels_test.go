@@ -0,0 +1,57 @@
+package disasm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/disasm"
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+func decode(t *testing.T, data []byte) []instruction.Instruction {
+	t.Helper()
+	instrs, err := lexer.New(reader.NewFromBytes(data)).All()
+	if err != nil {
+		t.Fatalf("decoding % x: %v", data, err)
+	}
+	return instrs
+}
+
+func TestLabels(t *testing.T) {
+	// the jnz section of listing_0041 followed by a jump into the middle of
+	// an instruction and one past the end
+	instrs := decode(t, []byte{
+		0x75, 0x02, 0x75, 0xfc, 0x75, 0xfa, 0x75, 0xfc,
+		0x74, 0xfe, 0xe2, 0xfc,
+		0xb8, 0x01, 0x00, 0xeb, 0xfc, 0xeb, 0x10,
+	})
+
+	labels := disasm.Labels(instrs)
+
+	var out strings.Builder
+	for _, in := range instrs {
+		if label, ok := labels[in.Address]; ok {
+			out.WriteString(label + ":\n")
+		}
+		out.WriteString(disasm.ApplyLabel(in, labels).String() + "\n")
+	}
+
+	want := `label_0:
+jne label_1
+jne label_0
+label_1:
+jne label_0
+jne label_1
+label_2:
+je label_2
+loop label_2
+mov ax, 1
+jmp $-2
+jmp $+18
+`
+	if out.String() != want {
+		t.Errorf("labelled listing. got=\n%s\nwant=\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,88 @@
+package instruction
+
+// opcodeDispatch lists, in table order, the encodings that can start with a
+// given byte. When several of them share that byte and are only told apart
+// by the reg field of the ModRM byte that follows, byReg narrows the list
+// down further.
+type opcodeDispatch struct {
+	encodings []InstructionEncoding
+	byReg     *[8][]InstructionEncoding
+}
+
+// literalMask returns the bits fixed by literals over the first two bytes of
+// an encoding, as a mask and the value those bits must have.
+func literalMask(encoding InstructionEncoding) (mask [2]byte, value [2]byte) {
+	pos := 0
+	for _, bit := range encoding.Bits {
+		if bit.Usage == Bits_End || pos >= 16 {
+			break
+		}
+		if bit.BitCount == 0 {
+			continue
+		}
+		if bit.Usage == Bits_Literal {
+			shift := 8 - (pos % 8) - int(bit.BitCount)
+			m := (byte(1<<bit.BitCount) - 1) << shift
+			mask[pos/8] |= m
+			value[pos/8] |= (bit.Value << shift) & m
+		}
+		pos += int(bit.BitCount)
+	}
+	return mask, value
+}
+
+// compileDispatch indexes the encodings by their first byte so that decoding
+// only has to try the handful of encodings that can actually match.
+func (it *InstructionTable) compileDispatch() {
+	masks := make([][2]byte, len(it.Encodings))
+	values := make([][2]byte, len(it.Encodings))
+	for i, encoding := range it.Encodings {
+		masks[i], values[i] = literalMask(encoding)
+	}
+
+	it.dispatch = &[256]opcodeDispatch{}
+	for b := range 256 {
+		d := &it.dispatch[b]
+		byRegOnly := true
+		for i, encoding := range it.Encodings {
+			if byte(b)&masks[i][0] != values[i][0] {
+				continue
+			}
+			d.encodings = append(d.encodings, encoding)
+			if masks[i][1]&^0b00111000 != 0 {
+				byRegOnly = false
+			}
+		}
+
+		if len(d.encodings) < 2 || !byRegOnly {
+			continue
+		}
+
+		d.byReg = &[8][]InstructionEncoding{}
+		for reg := range 8 {
+			modrm := byte(reg) << 3
+			for i, encoding := range it.Encodings {
+				if byte(b)&masks[i][0] != values[i][0] {
+					continue
+				}
+				if modrm&masks[i][1] == values[i][1] {
+					d.byReg[reg] = append(d.byReg[reg], encoding)
+				}
+			}
+		}
+	}
+}
+
+// candidates returns the encodings worth trying for the instruction starting
+// at the given opcode byte. next is the byte after it, when there is one.
+func (it *InstructionTable) candidates(opcode byte, next byte, hasNext bool) []InstructionEncoding {
+	if it.dispatch == nil {
+		return it.Encodings
+	}
+
+	d := &it.dispatch[opcode]
+	if d.byReg != nil && hasNext {
+		return d.byReg[(next>>3)&0b111]
+	}
+	return d.encodings
+}
@@ -0,0 +1,339 @@
+package instruction
+
+import "strings"
+
+// fieldSet holds the values of the fields of one encoding while an
+// instruction is being fitted to it. Fields given a value by the table
+// itself (BitCount 0) are fixed and can only be checked, not chosen.
+type fieldSet struct {
+	bits  [Bits_Count]byte
+	has   [Bits_Count]bool
+	fixed [Bits_Count]bool
+}
+
+func newFieldSet(encoding InstructionEncoding) fieldSet {
+	f := fieldSet{}
+	for _, bit := range encoding.Bits {
+		if bit.Usage == Bits_End {
+			break
+		}
+		f.has[bit.Usage] = true
+		if bit.BitCount == 0 {
+			f.bits[bit.Usage] |= bit.Value
+			f.fixed[bit.Usage] = true
+		}
+	}
+	return f
+}
+
+// set gives field u the value v, which only works for a field of the
+// encoding, and for a fixed one only when it already has that value.
+func (f *fieldSet) set(u InstructionBitsUsage, v byte) bool {
+	if !f.has[u] {
+		return false
+	}
+	if f.fixed[u] {
+		return f.bits[u] == v
+	}
+	f.bits[u] = v
+	return true
+}
+
+// choices lists the values worth trying for a one bit field, preferred
+// first: the table's value if it is fixed, 0 if the encoding lacks it.
+func (f *fieldSet) choices(u InstructionBitsUsage, preferred byte) []byte {
+	switch {
+	case !f.has[u]:
+		return []byte{0}
+	case f.fixed[u]:
+		return []byte{f.bits[u]}
+	default:
+		return []byte{preferred, preferred ^ 1}
+	}
+}
+
+// Encode assembles in back into machine code. Of the encodings of its
+// operation that can express it, the shortest is used, the first one in
+// table order on a tie. Direction is a preference between the two ways of
+// writing a register to register form, Mode is ignored: the memory operand
+// carries its displacement width. Relative targets are encoded as the
+// displacement in Value, 16 bits wide when Bits_W is in the immediate's
+// flags, which is how DecodeInstruction leaves them.
+func (it *InstructionTable) Encode(in Instruction) ([]byte, error) {
+	var best []byte
+	preferD := byte(0)
+	if in.Direction {
+		preferD = 1
+	}
+
+	for _, encoding := range it.Encodings {
+		if encoding.Op != in.Op {
+			continue
+		}
+		fields := newFieldSet(encoding)
+		for _, d := range fields.choices(Bits_D, preferD) {
+			for _, s := range fields.choices(Bits_S, 1) {
+				f := fields
+				out, ok := f.encode(encoding, in, d, s)
+				if ok && (best == nil || len(out) < len(best)) {
+					best = out
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, &EncodeError{Instruction: in}
+	}
+	return best, nil
+}
+
+// encode fits in to encoding with the given D and S bits, returning the
+// bytes when it fits.
+func (f *fieldSet) encode(encoding InstructionEncoding, in Instruction, d, s byte) ([]byte, bool) {
+	if f.has[Bits_D] && !f.set(Bits_D, d) {
+		return nil, false
+	}
+
+	w := f.bits[Bits_W] == 1
+	if f.has[Bits_W] && !f.fixed[Bits_W] {
+		w = in.Wide
+		f.bits[Bits_W] = boolBit(w)
+	}
+	dataW := w && f.bits[Bits_WMakesDataW] == 1
+	// a sign-extended byte only makes sense in place of a word
+	if s == 1 && (!dataW || !f.set(Bits_S, 1)) {
+		return nil, false
+	}
+
+	if (f.bits[Bits_Far] == 1) != (in.Flags&Inst_Far == Inst_Far) {
+		return nil, false
+	}
+	if f.has[Bits_Z] {
+		z := byte(0)
+		if in.Flags&Inst_Rep == Inst_Rep {
+			z = 1
+		}
+		if !f.set(Bits_Z, z) {
+			return nil, false
+		}
+	}
+
+	// D picks which operand goes in the REG field, see TryDecode
+	modOp, regOp := in.Reg, in.RM
+	if d == 1 {
+		modOp, regOp = in.RM, in.Reg
+	}
+
+	var disp, tail []byte
+	var data *InstructionOperand
+
+	switch {
+	case f.has[Bits_REG]:
+		code, ok := registerCode(regOp, w)
+		if !ok || !f.set(Bits_REG, code) {
+			return nil, false
+		}
+	case f.has[Bits_SR]:
+		if regOp.Type != Operand_Register || regOp.Class != Register_Segment || !f.set(Bits_SR, regOp.Code) {
+			return nil, false
+		}
+	case f.has[Bits_V]:
+		switch {
+		case regOp.Type == Operand_Register && strings.EqualFold(regOp.Name, "CL"):
+			f.set(Bits_V, 1)
+		case regOp.Type == Operand_Immediate && !isRelative(regOp) && regOp.Value == 1:
+			f.set(Bits_V, 0)
+		default:
+			return nil, false
+		}
+	case f.has[Bits_Data] && f.has[Bits_MOD]:
+		if regOp.Type != Operand_Immediate || isRelative(regOp) {
+			return nil, false
+		}
+		data = &regOp
+	default:
+		if regOp.Type != Operand_None {
+			return nil, false
+		}
+	}
+
+	switch {
+	case f.has[Bits_MOD]:
+		switch modOp.Type {
+		case Operand_Register:
+			code, ok := registerCode(modOp, w || f.bits[Bits_RMRegAlwaysW] == 1)
+			if !ok || f.bits[Bits_MemoryOnly] == 1 || !f.set(Bits_MOD, byte(Reg)) || !f.set(Bits_RM, code) {
+				return nil, false
+			}
+		case Operand_Memory:
+			mod, rm, bytes, ok := memoryEncoding(modOp.EffectiveAddressExpression)
+			if !ok || !f.set(Bits_MOD, byte(mod)) || !f.set(Bits_RM, rm) {
+				return nil, false
+			}
+			disp = bytes
+		default:
+			return nil, false
+		}
+	case f.has[Bits_Data] && f.has[Bits_Disp]:
+		if modOp.Type != Operand_FarPointer {
+			return nil, false
+		}
+		tail = append(littleEndian(modOp.OffsetValue, 2), littleEndian(modOp.SegmentValue, 2)...)
+	case f.has[Bits_RelJMPDisp]:
+		if !isRelative(modOp) {
+			return nil, false
+		}
+		size := 1
+		if f.bits[Bits_DispAlwaysW] == 1 {
+			size = 2
+		}
+		if (modOp.Immediate.Flags&int(Bits_W) == int(Bits_W)) != (size == 2) || !fitsSigned(modOp.Value, size, size == 2) {
+			return nil, false
+		}
+		tail = littleEndian(modOp.Value, size)
+	case f.has[Bits_Data]:
+		if modOp.Type != Operand_Immediate || isRelative(modOp) {
+			return nil, false
+		}
+		data = &modOp
+	default:
+		if modOp.Type != Operand_None {
+			return nil, false
+		}
+	}
+
+	if data != nil {
+		switch {
+		case dataW && s == 1:
+			if data.Value < -128 || data.Value > 127 {
+				return nil, false
+			}
+			tail = littleEndian(data.Value, 1)
+		case dataW:
+			if !fitsSigned(data.Value, 2, true) {
+				return nil, false
+			}
+			tail = littleEndian(data.Value, 2)
+		default:
+			if !fitsSigned(data.Value, 1, true) {
+				return nil, false
+			}
+			tail = littleEndian(data.Value, 1)
+		}
+	}
+
+	out := prefixBytes(in)
+	acc, count := 0, 0
+	for _, bit := range encoding.Bits {
+		if bit.Usage == Bits_End {
+			break
+		}
+		if bit.BitCount == 0 {
+			continue
+		}
+		v := f.bits[bit.Usage]
+		if bit.Usage == Bits_Literal {
+			v = bit.Value
+		}
+		acc = acc<<bit.BitCount | int(v&(1<<bit.BitCount-1))
+		count += int(bit.BitCount)
+		if count == 8 {
+			out = append(out, byte(acc))
+			acc, count = 0, 0
+		}
+	}
+	out = append(out, disp...)
+	return append(out, tail...), true
+}
+
+// prefixBytes returns the prefixes in's flags ask for, in the order NASM
+// writes them: repeat, lock, then the segment override.
+func prefixBytes(in Instruction) []byte {
+	out := []byte{}
+	if in.Op != Op_rep {
+		if in.Flags&Inst_Rep == Inst_Rep {
+			out = append(out, 0xf3)
+		}
+		if in.Flags&Inst_RepNE == Inst_RepNE {
+			out = append(out, 0xf2)
+		}
+	}
+	if in.Flags&Inst_Lock == Inst_Lock {
+		out = append(out, 0xf0)
+	}
+	if in.Flags&Inst_Segment == Inst_Segment {
+		out = append(out, 0x26|in.SegmentOverride.Code<<3)
+	}
+	return out
+}
+
+// registerCode returns the 3-bit code of op, when it is a general register
+// as wide as w says.
+func registerCode(op InstructionOperand, w bool) (byte, bool) {
+	if op.Type != Operand_Register || op.Class != Register_General || op.Code > 7 {
+		return 0, false
+	}
+	return op.Code, strings.EqualFold(registerTable[boolIndex(w)][op.Code].Name, op.Name)
+}
+
+// memoryEncoding returns the MOD and R/M fields that select eae, and its
+// displacement bytes, whose width is the one eae was built with.
+func memoryEncoding(eae EffectiveAddressExpression) (Mode, byte, []byte, bool) {
+	mode := Memory
+	switch eae.Displacement {
+	case 8:
+		mode = Displ8
+	case 16:
+		mode = Displ16
+	}
+	for rm, mem := range memoryTable[mode] {
+		if !strings.EqualFold(mem.Terms[0].Name, eae.Terms[0].Name) || !strings.EqualFold(mem.Terms[1].Name, eae.Terms[1].Name) {
+			continue
+		}
+		switch {
+		case mode == Memory && rm == 0b110:
+			if !fitsSigned(eae.DisplacementValue, 2, true) {
+				return 0, 0, nil, false
+			}
+			return mode, byte(rm), littleEndian(eae.DisplacementValue, 2), true
+		case mode == Memory:
+			return mode, byte(rm), nil, eae.DisplacementValue == 0
+		default:
+			size := int(eae.Displacement / 8)
+			if !fitsSigned(eae.DisplacementValue, size, false) {
+				return 0, 0, nil, false
+			}
+			return mode, byte(rm), littleEndian(eae.DisplacementValue, size), true
+		}
+	}
+	return 0, 0, nil, false
+}
+
+// fitsSigned tells whether v fits in size bytes as a signed number, or
+// also as an unsigned one when unsigned is set.
+func fitsSigned(v int, size int, unsigned bool) bool {
+	limit := 1 << (8*size - 1)
+	if unsigned {
+		return v >= -limit && v < 2*limit
+	}
+	return v >= -limit && v < limit
+}
+
+func littleEndian(v int, size int) []byte {
+	out := make([]byte, size)
+	for n := range out {
+		out[n] = byte(v >> (8 * n))
+	}
+	return out
+}
+
+func boolBit(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func boolIndex(b bool) int {
+	return int(boolBit(b))
+}
@@ -0,0 +1,149 @@
+package instruction_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+// decodeBytes decodes the single instruction data starts with.
+func decodeBytes(it *instruction.InstructionTable, data []byte) (instruction.Instruction, error) {
+	r := reader.NewFromBytes(data)
+	if _, err := r.ReadByte(); err != nil {
+		return instruction.Instruction{}, err
+	}
+	return it.DecodeInstruction(r)
+}
+
+// encodingFree clears what only tells which of several equivalent
+// encodings an instruction was read from, leaving what it means.
+func encodingFree(in instruction.Instruction) instruction.Instruction {
+	in.Direction = false
+	in.Mode = 0
+	in.Size = 0
+	in.Bytes = nil
+	in.Prefixes = nil
+	return in
+}
+
+func TestEncode(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+	tests := []struct {
+		input []byte
+		want  []byte
+	}{
+		{[]byte{0x89, 0xd9}, []byte{0x89, 0xd9}},                   // mov cx, bx
+		{[]byte{0x8b, 0xcb}, []byte{0x8b, 0xcb}},                   // mov cx, bx, D kept
+		{[]byte{0xc7, 0xc0, 0x05, 0x00}, []byte{0xb8, 0x05, 0x00}}, // mov ax, 5
+		{[]byte{0x8b, 0x06, 0x10, 0x00}, []byte{0xa1, 0x10, 0x00}}, // mov ax, [16]
+		{[]byte{0x81, 0xc1, 0x05, 0x00}, []byte{0x83, 0xc1, 0x05}}, // add cx, 5
+		{[]byte{0x80, 0xc0, 0x05}, []byte{0x04, 0x05}},             // add al, 5
+		{[]byte{0x05, 0xe8, 0x03}, []byte{0x05, 0xe8, 0x03}},       // add ax, 1000
+		{[]byte{0x8b, 0x46, 0x00}, []byte{0x8b, 0x46, 0x00}},       // mov ax, [bp]
+		{[]byte{0x8b, 0x87, 0x04, 0x00}, []byte{0x8b, 0x87, 0x04, 0x00}},
+		{[]byte{0x26, 0xf0, 0x87, 0x07}, []byte{0xf0, 0x26, 0x87, 0x07}}, // lock xchg [es:bx], ax
+		{[]byte{0xf3, 0xa5}, []byte{0xf3, 0xa5}},                         // rep movsw
+		{[]byte{0xeb, 0xfe}, []byte{0xeb, 0xfe}},                         // jmp $+0
+		{[]byte{0xe9, 0x00, 0x01}, []byte{0xe9, 0x00, 0x01}},             // jmp near
+		{[]byte{0x9a, 0x78, 0x56, 0x34, 0x12}, []byte{0x9a, 0x78, 0x56, 0x34, 0x12}},
+		{[]byte{0xd2, 0x2f}, []byte{0xd2, 0x2f}}, // shr byte [bx], cl
+		{[]byte{0xe4, 0x60}, []byte{0xe4, 0x60}}, // in al, 96
+		{[]byte{0xcd, 0x21}, []byte{0xcd, 0x21}}, // int 33
+		{[]byte{0xc2, 0x04, 0x00}, []byte{0xc2, 0x04, 0x00}},
+	}
+	for _, tt := range tests {
+		in, err := decodeBytes(&it, tt.input)
+		if err != nil {
+			t.Fatalf("% x does not decode: %v", tt.input, err)
+		}
+		got, err := it.Encode(in)
+		if err != nil {
+			t.Errorf("Encode(%s) returned %v", in, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("Encode(%s). got=% x want=% x", in, got, tt.want)
+		}
+	}
+}
+
+func TestEncode_Errors(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+	ax, _ := it.ResolveRegister(0, true)
+	bl, _ := it.ResolveRegister(3, false)
+	big, _ := it.ResolveImmediate(0x1234, 0)
+	big.Value = 0x1234
+
+	tests := []instruction.Instruction{
+		{Op: instruction.Op_mov, Wide: true, Reg: ax, RM: bl},
+		{Op: instruction.Op_mov, Reg: bl, RM: big},
+		{Op: instruction.Op_je, Reg: instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 300, Flags: instruction.Immediate_RelativeJumpDisplacement}}},
+		{Op: instruction.Op_push, Reg: bl},
+		{Op: instruction.Op_lea, Wide: true, Reg: ax, RM: ax},
+		{Op: instruction.Op_lds, Wide: true, Reg: ax, RM: ax},
+		{Op: instruction.Op_les, Wide: true, Reg: ax, RM: ax},
+	}
+	for _, in := range tests {
+		_, err := it.Encode(in)
+		var encErr *instruction.EncodeError
+		if !errors.As(err, &encErr) {
+			t.Errorf("Encode(%s) error. got=%v want an EncodeError", in, err)
+		}
+	}
+}
+
+// TestEncode_RoundTrip decodes every opcode and ModRM pair, followed by a
+// few different displacement and data bytes, and checks that encoding the
+// result decodes back to the same instruction, in no more bytes.
+func TestEncode_RoundTrip(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+	tails := [][]byte{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x05, 0x80, 0xff, 0x7f, 0x34, 0x12},
+		{0xfe, 0x7f, 0x80, 0x00, 0x01, 0x90},
+	}
+
+	forms := 0
+	for first := range 256 {
+		for second := range 256 {
+			for _, tail := range tails {
+				input := append([]byte{byte(first), byte(second)}, tail...)
+				x, err := decodeBytes(&it, input)
+				if err != nil {
+					continue
+				}
+				// a prefix only stands on its own in front of another of
+				// its group, it does not decode back by itself
+				if x.Op == instruction.Op_segment || x.Op == instruction.Op_lock || x.Op == instruction.Op_rep {
+					continue
+				}
+				forms++
+
+				encoded, err := it.Encode(x)
+				if err != nil {
+					t.Fatalf("Encode(%s) from % x returned %v", x, x.Bytes, err)
+				}
+				if len(encoded) > x.Size {
+					t.Errorf("Encode(%s) is %d bytes (% x), decoded from %d (% x)", x, len(encoded), encoded, x.Size, x.Bytes)
+				}
+				y, err := decodeBytes(&it, encoded)
+				if err != nil {
+					t.Fatalf("Encode(%s) = % x does not decode: %v", x, encoded, err)
+				}
+				if y.Size != len(encoded) {
+					t.Errorf("Encode(%s) = % x decodes as %d bytes", x, encoded, y.Size)
+				}
+				if !reflect.DeepEqual(encodingFree(x), encodingFree(y)) {
+					t.Fatalf("decode(encode(x)) != x for % x.\nx=%+v\ny=%+v", x.Bytes, encodingFree(x), encodingFree(y))
+				}
+			}
+		}
+	}
+	if forms == 0 {
+		t.Fatal("nothing decoded")
+	}
+}
@@ -0,0 +1,78 @@
+package instruction
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+type DecodeErrorReason int
+
+const (
+	DecodeError_UnknownOpcode DecodeErrorReason = iota
+	DecodeError_Truncated
+)
+
+func (r DecodeErrorReason) String() string {
+	switch r {
+	case DecodeError_UnknownOpcode:
+		return "unknown opcode"
+	case DecodeError_Truncated:
+		return "truncated instruction"
+	default:
+		return fmt.Sprintf("ERROR(REASON): %d", int(r))
+	}
+}
+
+// DecodeError reports bytes that could not be decoded into an instruction.
+// Offset is relative to the start of the reader's data and Bytes holds the
+// opcode that was not recognised (after any prefixes), or everything that
+// was left for a truncated instruction.
+type DecodeError struct {
+	Offset int
+	Bytes  []byte
+	Reason DecodeErrorReason
+}
+
+func (e *DecodeError) Error() string {
+	var out bytes.Buffer
+	for i, b := range e.Bytes {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(fmt.Sprintf("%02x", b))
+	}
+	return fmt.Sprintf("%s at offset 0x%04x (%s)", e.Reason, e.Offset, out.String())
+}
+
+func newDecodeError(r *reader.Reader, start int, err error) error {
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		return err
+	}
+
+	// the reader is left just past the opcode that failed to match, which
+	// is after any prefixes that were already consumed
+	end := min(max(r.SegmentOffset, start+1), len(r.Data))
+	if decErr.Reason == DecodeError_Truncated {
+		end = len(r.Data)
+	}
+	return &DecodeError{
+		Offset: start,
+		Bytes:  r.Data[start:end],
+		Reason: decErr.Reason,
+	}
+}
+
+// EncodeError reports an instruction that none of the encodings of its
+// operation can express, such as an operand the operation does not take
+// or an immediate too wide for its field.
+type EncodeError struct {
+	Instruction Instruction
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("no encoding for %s", e.Instruction)
+}
@@ -0,0 +1,128 @@
+package instruction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formatter renders decoded instructions in the syntax of one assembler
+// family. Instruction.String uses NASM with the default options.
+type Formatter interface {
+	Format(i Instruction) string
+	// Comment turns text into a comment line in the same syntax.
+	Comment(text string) string
+}
+
+type FormatOptions struct {
+	// Hex prints immediates and displacements in hexadecimal instead of
+	// decimal. Relative jumps keep their $+N form.
+	Hex bool
+}
+
+// NewFormatter returns the formatter for syntax, one of "nasm", "masm"
+// (which also covers TASM) or "att".
+func NewFormatter(syntax string, opts FormatOptions) (Formatter, error) {
+	switch strings.ToLower(syntax) {
+	case "nasm", "":
+		return NASMFormatter{opts}, nil
+	case "masm", "tasm":
+		return MASMFormatter{opts}, nil
+	case "att", "gas":
+		return ATTFormatter{opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown syntax %q", syntax)
+	}
+}
+
+type operandWidth int
+
+const (
+	width_None operandWidth = iota
+	width_Byte
+	width_Word
+	width_Far
+)
+
+// memoryWidth tells whether the size of the memory access has to be
+// spelled out, which is the case when no register operand pins it down.
+func memoryWidth(i Instruction) operandWidth {
+	if i.Reg.Type != Operand_Memory || (i.RM.Type == Operand_Register && !i.IsShift()) {
+		return width_None
+	}
+	if i.Flags&Inst_Far == Inst_Far {
+		return width_Far
+	}
+	if i.Wide {
+		return width_Word
+	}
+	return width_Byte
+}
+
+// prefixText returns the lock and repeat prefixes written in front of the
+// mnemonic, which read the same in every syntax.
+func prefixText(i Instruction) string {
+	var out strings.Builder
+
+	if i.Flags&Inst_Lock == Inst_Lock {
+		out.WriteString("lock ")
+	}
+	// a rep prefix decoded on its own carries its flavour in the flags
+	// too, that is printed as the mnemonic
+	if i.Op != Op_rep {
+		if i.Flags&Inst_Rep == Inst_Rep {
+			if i.Op == Op_cmps || i.Op == Op_scas {
+				out.WriteString("repe ")
+			} else {
+				out.WriteString("rep ")
+			}
+		}
+		if i.Flags&Inst_RepNE == Inst_RepNE {
+			out.WriteString("repne ")
+		}
+	}
+	return out.String()
+}
+
+// standaloneSegment returns the override of an instruction without an
+// explicit memory operand, which still has to be kept as a prefix of its own.
+func standaloneSegment(i Instruction) (string, bool) {
+	if i.Flags&Inst_Segment == Inst_Segment && i.Reg.Type != Operand_Memory && i.RM.Type != Operand_Memory {
+		return strings.ToLower(i.SegmentOverride.Name), true
+	}
+	return "", false
+}
+
+func mnemonic(i Instruction) string {
+	m := i.Op.String()
+	if i.Op == Op_rep && i.Flags&Inst_RepNE == Inst_RepNE {
+		m += "ne"
+	}
+	if i.IsString() {
+		if i.Wide {
+			m += "w"
+		} else {
+			m += "b"
+		}
+	}
+	return m
+}
+
+func isRelative(op InstructionOperand) bool {
+	return op.Type == Operand_Immediate && op.Immediate.Flags&Immediate_RelativeJumpDisplacement == Immediate_RelativeJumpDisplacement
+}
+
+// signedNumber prints v in decimal, or in hex through hex keeping the sign
+// in front.
+func signedNumber(v int, hexadecimal bool, hex func(v int) string) string {
+	if !hexadecimal {
+		return fmt.Sprintf("%d", v)
+	}
+	if v < 0 {
+		return "-" + hex(-v)
+	}
+	return hex(v)
+}
+
+func cHex(v int) string {
+	return fmt.Sprintf("0x%x", v)
+}
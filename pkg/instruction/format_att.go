@@ -0,0 +1,135 @@
+package instruction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ATTFormatter prints the AT&T syntax of the GNU assembler: source first,
+// `%` registers, `$` immediates, `movw $1,4(%bx)`.
+type ATTFormatter struct {
+	FormatOptions
+}
+
+func (f ATTFormatter) Format(i Instruction) string {
+	var out strings.Builder
+
+	if i.Op == Op_db {
+		return fmt.Sprintf(".byte 0x%02x", i.Reg.Value)
+	}
+	// a segment prefix decoded on its own is written as its register
+	if i.Op == Op_segment {
+		return strings.ToLower(i.Reg.Name)
+	}
+
+	out.WriteString(prefixText(i))
+	if segment, ok := standaloneSegment(i); ok {
+		out.WriteString(segment + " ")
+	}
+
+	out.WriteString(f.mnemonic(i))
+	if i.Reg.Type != Operand_None || i.RM.Type != Operand_None {
+		out.WriteString(" ")
+	}
+
+	// indirect transfers mark their operand with a *
+	if (i.Op == Op_jmp || i.Op == Op_call) && (i.Reg.Type == Operand_Register || i.Reg.Type == Operand_Memory) {
+		out.WriteString("*")
+	}
+
+	operands := []string{}
+	if i.RM.Type != Operand_None {
+		operands = append(operands, f.operand(i.RM, i.Size))
+	}
+	if i.Reg.Type != Operand_None {
+		operands = append(operands, f.operand(i.Reg, i.Size))
+	}
+	out.WriteString(strings.Join(operands, ","))
+
+	return out.String()
+}
+
+func (f ATTFormatter) Comment(text string) string {
+	return "# " + text
+}
+
+// mnemonic adds the b/w suffix where no register gives away the operand
+// size and renames what GAS spells differently.
+func (f ATTFormatter) mnemonic(i Instruction) string {
+	switch i.Op {
+	case Op_cbw:
+		return "cbtw"
+	case Op_cwd:
+		return "cwtd"
+	case Op_retf:
+		return "lret"
+	case Op_jmp, Op_call:
+		if i.Flags&Inst_Far == Inst_Far || i.Reg.Type == Operand_FarPointer {
+			return "l" + i.Op.String()
+		}
+	}
+
+	m := mnemonic(i)
+	switch memoryWidth(i) {
+	case width_Word:
+		m += "w"
+	case width_Byte:
+		m += "b"
+	}
+	return m
+}
+
+func (f ATTFormatter) operand(op InstructionOperand, size int) string {
+	switch op.Type {
+	case Operand_Register:
+		return "%" + strings.ToLower(op.Name)
+	case Operand_Immediate:
+		if isRelative(op) {
+			if op.Label != "" {
+				return op.Label
+			}
+			return fmt.Sprintf(".%+d", op.Value+size)
+		}
+		if op.Label != "" {
+			return "$" + op.Label
+		}
+		return "$" + f.number(op.Value)
+	case Operand_Memory:
+		return f.address(op.EffectiveAddressExpression)
+	case Operand_FarPointer:
+		return fmt.Sprintf("$0x%x,$0x%x", op.SegmentValue, op.OffsetValue)
+	}
+	return fmt.Sprintf("ERROR(REG): %v %v %v", op.Type, op.Name, op.Code)
+}
+
+func (f ATTFormatter) address(eae EffectiveAddressExpression) string {
+	var out strings.Builder
+
+	if eae.Segment.Name != "" {
+		out.WriteString("%" + strings.ToLower(eae.Segment.Name) + ":")
+	}
+	if eae.Terms[0].Name == "" && eae.Terms[1].Name == "" {
+		if eae.DisplacementLabel != "" {
+			out.WriteString(eae.DisplacementLabel)
+		} else {
+			out.WriteString(f.number(eae.DisplacementValue))
+		}
+		return out.String()
+	}
+
+	if eae.DisplacementLabel != "" {
+		out.WriteString(eae.DisplacementLabel)
+	} else if eae.DisplacementValue != 0 {
+		out.WriteString(f.number(eae.DisplacementValue))
+	}
+	out.WriteString("(%" + strings.ToLower(eae.Terms[0].Name))
+	if eae.Terms[1].Name != "" {
+		out.WriteString(",%" + strings.ToLower(eae.Terms[1].Name))
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+func (f ATTFormatter) number(v int) string {
+	return signedNumber(v, f.Hex, cHex)
+}
@@ -0,0 +1,160 @@
+package instruction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MASMFormatter prints MASM/TASM syntax: `mov word ptr [bx+4], 1`,
+// `mov ax, offset label`, with hex numbers written as `0ffh`.
+type MASMFormatter struct {
+	FormatOptions
+}
+
+func (f MASMFormatter) Format(i Instruction) string {
+	var out strings.Builder
+
+	if i.Op == Op_db {
+		return "db " + masmHex(i.Reg.Value)
+	}
+	// a segment prefix decoded on its own is written as its register
+	if i.Op == Op_segment {
+		return strings.ToLower(i.Reg.Name)
+	}
+	if i.Reg.Type == Operand_FarPointer {
+		return f.farPointer(i)
+	}
+
+	out.WriteString(prefixText(i))
+	if segment, ok := standaloneSegment(i); ok {
+		out.WriteString(segment + ": ")
+	}
+
+	out.WriteString(mnemonic(i))
+	if i.Reg.Type != Operand_None || i.RM.Type != Operand_None {
+		out.WriteString(" ")
+	}
+
+	// the size always goes on the memory operand, mov included
+	width := ""
+	switch memoryWidth(i) {
+	case width_Far:
+		width = "dword ptr "
+	case width_Word:
+		width = "word ptr "
+	case width_Byte:
+		width = "byte ptr "
+	}
+
+	if i.Reg.Type != Operand_None {
+		out.WriteString(width)
+		if i.needsNearJump() {
+			out.WriteString("near ptr ")
+		}
+		out.WriteString(f.operand(i.Reg, i.Size))
+	}
+	if i.Reg.Type != Operand_None && i.RM.Type != Operand_None {
+		out.WriteString(", ")
+	}
+	if i.RM.Type != Operand_None {
+		out.WriteString(f.operand(i.RM, i.Size))
+	}
+
+	return out.String()
+}
+
+func (f MASMFormatter) Comment(text string) string {
+	return "; " + text
+}
+
+func (f MASMFormatter) operand(op InstructionOperand, size int) string {
+	switch op.Type {
+	case Operand_Register:
+		return strings.ToLower(op.Name)
+	case Operand_Immediate:
+		if isRelative(op) {
+			if op.Label != "" {
+				return op.Label
+			}
+			return fmt.Sprintf("$%+d", op.Value+size)
+		}
+		if op.Label != "" {
+			return "offset " + op.Label
+		}
+		return f.number(op.Value)
+	case Operand_Memory:
+		return f.address(op.EffectiveAddressExpression)
+	}
+	return fmt.Sprintf("ERROR(REG): %v %v %v", op.Type, op.Name, op.Code)
+}
+
+// farPointer prints a direct far jmp or call as its bytes. MASM has no
+// spelling for a literal segment:offset target, only for a label in a far
+// segment, so its listings show the bytes and the target in a comment.
+func (f MASMFormatter) farPointer(i Instruction) string {
+	data := i.Bytes
+	if len(data) == 0 {
+		opcode := byte(0xea)
+		if i.Op == Op_call {
+			opcode = 0x9a
+		}
+		off, seg := i.Reg.OffsetValue, i.Reg.SegmentValue
+		data = []byte{opcode, byte(off), byte(off >> 8), byte(seg), byte(seg >> 8)}
+	}
+	values := make([]string, len(data))
+	for n, b := range data {
+		values[n] = masmHex(int(b))
+	}
+	target := fmt.Sprintf("%s %s:%s", mnemonic(i), masmHex(i.Reg.SegmentValue), masmHex(i.Reg.OffsetValue))
+	return "db " + strings.Join(values, ", ") + " " + f.Comment(target)
+}
+
+func (f MASMFormatter) address(eae EffectiveAddressExpression) string {
+	var out strings.Builder
+
+	if eae.Terms[0].Name == "" && eae.Terms[1].Name == "" {
+		// MASM reads a bare [n] as the constant n, a direct address needs
+		// its segment spelled out
+		segment := "ds"
+		if eae.Segment.Name != "" {
+			segment = strings.ToLower(eae.Segment.Name)
+		}
+		if eae.DisplacementLabel != "" {
+			return fmt.Sprintf("%s:[%s]", segment, eae.DisplacementLabel)
+		}
+		return fmt.Sprintf("%s:[%s]", segment, f.number(eae.DisplacementValue))
+	}
+
+	if eae.Segment.Name != "" {
+		out.WriteString(strings.ToLower(eae.Segment.Name) + ":")
+	}
+	out.WriteString("[" + strings.ToLower(eae.Terms[0].Name))
+	if eae.Terms[1].Name != "" {
+		out.WriteString("+" + strings.ToLower(eae.Terms[1].Name))
+	}
+	if label, negative := strings.CutPrefix(eae.DisplacementLabel, "-"); eae.DisplacementLabel != "" && negative {
+		out.WriteString("-" + label)
+	} else if eae.DisplacementLabel != "" {
+		out.WriteString("+" + eae.DisplacementLabel)
+	} else if eae.DisplacementValue < 0 {
+		out.WriteString("-" + f.number(-eae.DisplacementValue))
+	} else if eae.DisplacementValue > 0 {
+		out.WriteString("+" + f.number(eae.DisplacementValue))
+	}
+	out.WriteString("]")
+	return out.String()
+}
+
+func (f MASMFormatter) number(v int) string {
+	return signedNumber(v, f.Hex, masmHex)
+}
+
+// masmHex writes v with the h suffix, with a leading 0 when it would
+// otherwise start with a letter and read as a name.
+func masmHex(v int) string {
+	s := fmt.Sprintf("%xh", v)
+	if s[0] >= 'a' && s[0] <= 'f' {
+		return "0" + s
+	}
+	return s
+}
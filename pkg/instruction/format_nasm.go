@@ -0,0 +1,131 @@
+package instruction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NASMFormatter prints NASM syntax: `mov word [bx + 4], 1`, `jmp $+2`.
+type NASMFormatter struct {
+	FormatOptions
+}
+
+func (f NASMFormatter) Format(i Instruction) string {
+	var out strings.Builder
+
+	if i.Op == Op_db {
+		return fmt.Sprintf("db 0x%02x", i.Reg.Value)
+	}
+	// a segment prefix decoded on its own is written as its register
+	if i.Op == Op_segment {
+		return strings.ToLower(i.Reg.Name)
+	}
+
+	out.WriteString(prefixText(i))
+	if segment, ok := standaloneSegment(i); ok {
+		out.WriteString(segment + " ")
+	}
+
+	out.WriteString(mnemonic(i))
+	if i.Reg.Type != Operand_None || i.RM.Type != Operand_None {
+		out.WriteString(" ")
+	}
+
+	// mov carries the width on the immediate, everything else on the
+	// memory operand
+	width := ""
+	switch memoryWidth(i) {
+	case width_Far:
+		width = "far "
+	case width_Word:
+		width = "word "
+	case width_Byte:
+		width = "byte "
+	}
+
+	if i.Reg.Type != Operand_None {
+		if i.Op != Op_mov {
+			out.WriteString(width)
+		}
+		if i.needsNearJump() {
+			out.WriteString("near ")
+		}
+		out.WriteString(f.operand(i.Reg, i.Size))
+	}
+	if i.Reg.Type != Operand_None && i.RM.Type != Operand_None {
+		out.WriteString(", ")
+	}
+	if i.RM.Type != Operand_None {
+		if i.Op == Op_mov {
+			out.WriteString(width)
+		}
+		out.WriteString(f.operand(i.RM, i.Size))
+	}
+
+	return out.String()
+}
+
+func (f NASMFormatter) Comment(text string) string {
+	return "; " + text
+}
+
+// operand prints op for an instruction size bytes long, which relative
+// jumps need to be written from the start of the instruction.
+func (f NASMFormatter) operand(op InstructionOperand, size int) string {
+	switch op.Type {
+	case Operand_Register:
+		return strings.ToLower(op.Name)
+	case Operand_Immediate:
+		if op.Label != "" {
+			return op.Label
+		}
+		if isRelative(op) {
+			return fmt.Sprintf("$%+d", op.Value+size)
+		}
+		return f.number(op.Value)
+	case Operand_Memory:
+		return f.address(op.EffectiveAddressExpression)
+	case Operand_FarPointer:
+		return fmt.Sprintf("0x%04x:0x%04x", op.SegmentValue, op.OffsetValue)
+	}
+	return fmt.Sprintf("ERROR(REG): %v %v %v", op.Type, op.Name, op.Code)
+}
+
+func (f NASMFormatter) address(eae EffectiveAddressExpression) string {
+	var out strings.Builder
+
+	out.WriteString("[")
+	if eae.Segment.Name != "" {
+		out.WriteString(strings.ToLower(eae.Segment.Name) + ":")
+	}
+
+	if eae.Terms[0].Name == "" && eae.Terms[1].Name == "" {
+		if eae.DisplacementLabel != "" {
+			out.WriteString(eae.DisplacementLabel)
+		} else {
+			out.WriteString(f.number(eae.DisplacementValue))
+		}
+		out.WriteString("]")
+		return out.String()
+	}
+
+	out.WriteString(strings.ToLower(eae.Terms[0].Name))
+	if eae.Terms[1].Name != "" {
+		out.WriteString(" + " + strings.ToLower(eae.Terms[1].Name))
+	}
+	if label, negative := strings.CutPrefix(eae.DisplacementLabel, "-"); eae.DisplacementLabel != "" && negative {
+		out.WriteString(" - " + label)
+	} else if eae.DisplacementLabel != "" {
+		out.WriteString(" + " + eae.DisplacementLabel)
+	} else if eae.DisplacementValue < 0 {
+		out.WriteString(" - " + f.number(-eae.DisplacementValue))
+	} else if eae.DisplacementValue > 0 {
+		out.WriteString(" + " + f.number(eae.DisplacementValue))
+	}
+	out.WriteString("]")
+	return out.String()
+}
+
+func (f NASMFormatter) number(v int) string {
+	return signedNumber(v, f.Hex, cHex)
+}
@@ -0,0 +1,109 @@
+package instruction_test
+
+import (
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+func TestFormatter(t *testing.T) {
+	program := []byte{
+		0x26, 0x8b, 0x40, 0x04, // mov ax, [es:bx + si + 4]
+		0xc7, 0x47, 0xfe, 0x0a, 0x00, // mov word [bx - 2], 10
+		0xff, 0x1f, // call far [bx]
+		0xea, 0x34, 0x12, 0x78, 0x56, // jmp 0x5678:0x1234
+		0xa1, 0x34, 0x12, // mov ax, [4660]
+		0xe2, 0xfe, // loop $+0
+		0xd1, 0x27, // shl word [bx], 1
+		0x98,       // cbw
+		0xf3, 0xa5, // rep movsw
+		0xff, 0xe3, // jmp bx
+		0xca, 0x04, 0x00, // retf 4
+	}
+
+	tests := []struct {
+		syntax string
+		opts   instruction.FormatOptions
+		want   []string
+	}{
+		{syntax: "nasm", want: []string{
+			"mov ax, [es:bx + si + 4]", "mov [bx - 2], word 10", "call far [bx]", "jmp 0x5678:0x1234",
+			"mov ax, [4660]", "loop $+0", "shl word [bx], 1", "cbw", "rep movsw", "jmp bx", "retf 4",
+		}},
+		{syntax: "nasm", opts: instruction.FormatOptions{Hex: true}, want: []string{
+			"mov ax, [es:bx + si + 0x4]", "mov [bx - 0x2], word 0xa", "call far [bx]", "jmp 0x5678:0x1234",
+			"mov ax, [0x1234]", "loop $+0", "shl word [bx], 0x1", "cbw", "rep movsw", "jmp bx", "retf 0x4",
+		}},
+		{syntax: "masm", opts: instruction.FormatOptions{Hex: true}, want: []string{
+			"mov ax, es:[bx+si+4h]", "mov word ptr [bx-2h], 0ah", "call dword ptr [bx]", "db 0eah, 34h, 12h, 78h, 56h ; jmp 5678h:1234h",
+			"mov ax, ds:[1234h]", "loop $+0", "shl word ptr [bx], 1h", "cbw", "rep movsw", "jmp bx", "retf 4h",
+		}},
+		{syntax: "att", want: []string{
+			"mov %es:4(%bx,%si),%ax", "movw $10,-2(%bx)", "lcall *(%bx)", "ljmp $0x5678,$0x1234",
+			"mov 4660,%ax", "loop .+0", "shlw $1,(%bx)", "cbtw", "rep movsw", "jmp *%bx", "lret $4",
+		}},
+	}
+
+	it := instruction.New8086InstructionTable()
+	for _, tt := range tests {
+		f, err := instruction.NewFormatter(tt.syntax, tt.opts)
+		if err != nil {
+			t.Fatalf("NewFormatter(%q): %v", tt.syntax, err)
+		}
+
+		n := 0
+		decodeAll(t, &it, program, func(offset int, in instruction.Instruction, err error) {
+			if err != nil {
+				t.Fatalf("decoding at %d: %v", offset, err)
+			}
+			if n >= len(tt.want) {
+				t.Fatalf("%s: unexpected instruction %s", tt.syntax, f.Format(in))
+			}
+			if got := f.Format(in); got != tt.want[n] {
+				t.Errorf("%s Format(). got=%s want=%s", tt.syntax, got, tt.want[n])
+			}
+			n++
+		})
+		if n != len(tt.want) {
+			t.Errorf("%s: decoded %d instructions, want %d", tt.syntax, n, len(tt.want))
+		}
+	}
+
+	// without the bytes it was decoded from, a far pointer is encoded again
+	call := instruction.Instruction{Op: instruction.Op_call, Reg: instruction.InstructionOperand{Type: instruction.Operand_FarPointer, FarPointer: instruction.FarPointer{SegmentValue: 0xf000, OffsetValue: 0xfff0}}}
+	if got, want := (instruction.MASMFormatter{}).Format(call), "db 9ah, 0f0h, 0ffh, 0h, 0f0h ; call 0f000h:0fff0h"; got != want {
+		t.Errorf("masm Format(). got=%s want=%s", got, want)
+	}
+
+	if _, err := instruction.NewFormatter("intel", instruction.FormatOptions{}); err == nil {
+		t.Errorf("NewFormatter(intel) expected an error")
+	}
+}
+
+// FuzzFormat prints whatever decodes from arbitrary bytes in every syntax.
+func FuzzFormat(f *testing.F) {
+	f.Add([]byte{0x26, 0x8b, 0x40, 0x04, 0xff, 0x1f, 0xea, 0x34, 0x12, 0x78, 0x56})
+	it := instruction.New8086InstructionTable()
+	formatters := []instruction.Formatter{}
+	for _, syntax := range []string{"nasm", "masm", "att"} {
+		for _, hex := range []bool{false, true} {
+			formatter, err := instruction.NewFormatter(syntax, instruction.FormatOptions{Hex: hex})
+			if err != nil {
+				f.Fatal(err)
+			}
+			formatters = append(formatters, formatter)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeAll(t, &it, data, func(offset int, in instruction.Instruction, err error) {
+			if err != nil {
+				return
+			}
+			for _, formatter := range formatters {
+				if formatter.Format(in) == "" {
+					t.Fatalf("% x at %d: %T printed nothing", data, offset, formatter)
+				}
+			}
+		})
+	})
+}
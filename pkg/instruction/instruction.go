@@ -1,9 +1,7 @@
 package instruction
 
 import (
-	"bytes"
 	"fmt"
-	"strings"
 )
 
 type OperandType int
@@ -13,6 +11,7 @@ const (
 	Operand_Register
 	Operand_Memory
 	Operand_Immediate
+	Operand_FarPointer
 )
 
 type OpCode uint8
@@ -57,100 +56,101 @@ type EffectiveAddressExpression struct {
 	DisplacementValue int
 	Displacement      int
 	Flags             int
+	// Segment is the register named by a segment override prefix, if any.
+	Segment Register
+	// DisplacementLabel, when set, is printed in place of the displacement.
+	DisplacementLabel string
 }
 
 func (eae EffectiveAddressExpression) String() string {
-	var out bytes.Buffer
-
-	if eae.Terms[0].Name == "" && eae.Terms[1].Name == "" && eae.DisplacementValue != 0 {
-		return fmt.Sprintf("[%d]", eae.DisplacementValue)
-	}
-
-	out.WriteString(strings.ToLower(eae.Terms[0].Name))
-	if eae.Terms[1].Code > 0 {
-		out.WriteString(fmt.Sprintf(" + %s", strings.ToLower(eae.Terms[1].Name)))
-	}
-	if eae.DisplacementValue != 0 {
-		if eae.DisplacementValue < 0 {
-			out.WriteString(fmt.Sprintf(" - %d", eae.DisplacementValue*-1))
-		} else {
-			out.WriteString(fmt.Sprintf(" + %d", eae.DisplacementValue))
-		}
-	}
-	return fmt.Sprintf("[%s]", out.String())
+	return NASMFormatter{}.address(eae)
 }
 
-const Immediate_RelativeJumpDisplacement = 0x1
+const (
+	Immediate_RelativeJumpDisplacement = 0x1
+	Immediate_Unsigned                 = 0x2
+)
 
 type Immediate struct {
 	Value int
 	Flags int
 	Size  int
+	// Label, when set, is printed in place of a relative jump displacement.
+	Label string
 }
 
+// FarPointer is an immediate segment:offset pair, as used by direct
+// intersegment calls and jumps.
+type FarPointer struct {
+	SegmentValue int
+	OffsetValue  int
+}
+
+type RegisterClass int
+
+const (
+	Register_General RegisterClass = iota
+	Register_Segment
+)
+
 type Register struct {
-	Name string
-	Code byte
+	Name  string
+	Code  byte
+	Class RegisterClass
 }
 
 func (r InstructionOperand) String() string {
-	if r.Type == Operand_Register {
-		return strings.ToLower(r.Name)
-	}
-	if r.Type == Operand_Immediate {
-		if r.Immediate.Flags&Immediate_RelativeJumpDisplacement == Immediate_RelativeJumpDisplacement {
-			return fmt.Sprintf("$%+d", r.Value+r.Size)
-		}
-		return fmt.Sprintf("%d", r.Value)
-	}
-	if r.Type == Operand_Memory {
-		return r.EffectiveAddressExpression.String()
-	}
-	return fmt.Sprintf("ERROR(REG): %v %v %v", r.Type, r.Name, r.Code)
+	return NASMFormatter{}.operand(r, r.Size)
 }
 
 func (i Instruction) String() string {
-	var out bytes.Buffer
-
-	if i.Reg.Type == Operand_Immediate {
-		i.Reg.Size = i.Size
-	}
-	if i.RM.Type == Operand_Immediate {
-		i.RM.Size = i.Size
-	}
-
-	out.WriteString(i.Op.String() + " ")
+	return NASMFormatter{}.Format(i)
+}
 
-	if i.Reg.Type != Operand_None {
-		if i.IsArithmetic() && i.Reg.Type == Operand_Memory && i.RM.Type == Operand_Immediate {
-			if i.Wide {
-				out.WriteString("word ")
-			} else {
-				out.WriteString("byte ")
-			}
+// BranchTarget returns the absolute address a relative jump, call or loop
+// transfers control to. Targets wrap around within the 64KB the instruction
+// sits in, as they would within its code segment.
+func (i Instruction) BranchTarget() (int, bool) {
+	for _, op := range []InstructionOperand{i.Reg, i.RM} {
+		if op.Type == Operand_Immediate && op.Immediate.Flags&Immediate_RelativeJumpDisplacement == Immediate_RelativeJumpDisplacement {
+			return i.Address&^0xffff | (i.Address+i.Size+op.Value)&0xffff, true
 		}
-		out.WriteString(i.Reg.String())
 	}
-	if i.Reg.Type != Operand_None && i.RM.Type != Operand_None {
-		out.WriteString(", ")
+	return 0, false
+}
+
+// needsNearJump tells whether a jmp was encoded with a 16-bit displacement
+// although the short form would have reached, in which case an assembler
+// has to be told not to pick the short one.
+func (i Instruction) needsNearJump() bool {
+	if i.Op != Op_jmp || i.Reg.Type != Operand_Immediate || i.Reg.Immediate.Flags&int(Bits_W) != int(Bits_W) {
+		return false
 	}
-	if i.RM.Type != Operand_None {
-		if !i.IsArithmetic() && i.Reg.Type == Operand_Memory && i.RM.Type == Operand_Immediate {
-			if i.Wide {
-				out.WriteString("word ")
-			} else {
-				out.WriteString("byte ")
-			}
-		}
-		out.WriteString(i.RM.String())
+	short := i.Reg.Value + i.Size - 2
+	return short >= -128 && short <= 127
+}
+
+func (i Instruction) IsArithmetic() bool {
+	switch i.Op {
+	case Op_add, Op_adc, Op_sub, Op_sbb, Op_cmp, Op_and, Op_or, Op_xor, Op_test:
+		return true
+	default:
+		return false
 	}
+}
 
-	return out.String()
+func (i Instruction) IsString() bool {
+	switch i.Op {
+	case Op_movs, Op_cmps, Op_scas, Op_lods, Op_stos:
+		return true
+	default:
+		return false
+	}
 }
 
-func (i Instruction) IsArithmetic() bool {
+func (i Instruction) IsShift() bool {
 	switch i.Op {
-	case Op_add, Op_sub, Op_cmp:
+	case Op_shl, Op_shr, Op_sar, Op_rol, Op_ror, Op_rcl, Op_rcr:
 		return true
 	default:
 		return false
@@ -162,8 +162,17 @@ type InstructionOperand struct {
 	Immediate
 	EffectiveAddressExpression
 	Register
+	FarPointer
 }
 
+const (
+	Inst_Segment = 0x1
+	Inst_Lock    = 0x2
+	Inst_Rep     = 0x4
+	Inst_RepNE   = 0x8
+	Inst_Far     = 0x10
+)
+
 type Instruction struct {
 	Direction bool
 	Wide      bool
@@ -171,6 +180,18 @@ type Instruction struct {
 	Reg       InstructionOperand
 	RM        InstructionOperand
 	Size      int
+	Flags     int
+	// Address is where the instruction (including its prefixes) starts,
+	// counted from the reader's origin.
+	Address int
+	// Bytes are the encoded instruction, prefixes included, and Prefixes
+	// the leading lock, rep and segment override bytes in the order they
+	// were found. Both share the reader's data rather than copying it.
+	Bytes    []byte
+	Prefixes []byte
+
+	// SegmentOverride is only meaningful when Flags has Inst_Segment set.
+	SegmentOverride Register
 
 	Op OperationType
 }
@@ -197,6 +218,8 @@ const (
 	Bits_RMRegAlwaysW
 	Bits_RelJMPDisp
 	Bits_Far
+	Bits_DataUnsigned
+	Bits_MemoryOnly
 
 	Bits_Count
 )
@@ -4,6 +4,10 @@ type InstructionTable struct {
 	Encodings               []InstructionEncoding
 	EncodingCount           int
 	MaxInstructionByteCount int
+
+	// dispatch is built by New8086InstructionTable, a table assembled by
+	// hand without it falls back to trying every encoding in order.
+	dispatch *[256]opcodeDispatch
 }
 
 var InstructionTable8086 = []InstructionEncoding{
@@ -65,9 +69,10 @@ var InstructionTable8086 = []InstructionEncoding{
 			{Bits_Literal, 6, 0, 0b100011},
 			{Bits_D, 1, 0, 0},
 			{Bits_Literal, 1, 0, 0b0},
-			{Bits_MOD, 0, 0, 0},
+			{Bits_MOD, 2, 0, 0},
 			{Bits_Literal, 1, 0, 0b0},
 			{Bits_SR, 2, 0, 0},
+			{Bits_RM, 3, 0, 0},
 			{Bits_W, 0, 0, 0b1},
 		},
 	},
@@ -75,11 +80,10 @@ var InstructionTable8086 = []InstructionEncoding{
 	// PUSH
 	{Op_push, []InstructionBits{
 		{Bits_Literal, 8, 0, 0b11111111},
-		{Bits_MOD, 3, 0, 0},
-		{Bits_Literal, 3, 0, 0b0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b110},
 		{Bits_RM, 3, 0, 0},
 		{Bits_W, 0, 0, 1},
-		{Bits_D, 0, 0, 1},
 	}},
 	{Op_push, []InstructionBits{
 		{Bits_Literal, 5, 0, 0b01010},
@@ -95,6 +99,124 @@ var InstructionTable8086 = []InstructionEncoding{
 		{Bits_D, 0, 0, 1},
 	}},
 
+	// POP
+	{Op_pop, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10001111},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b000},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+	}},
+	{Op_pop, []InstructionBits{
+		{Bits_Literal, 5, 0, 0b01011},
+		{Bits_REG, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+	}},
+	{Op_pop, []InstructionBits{
+		{Bits_Literal, 3, 0, 0b000},
+		{Bits_SR, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b111},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// XCHG
+	{Op_xchg, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1000011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_xchg, []InstructionBits{
+		{Bits_Literal, 5, 0, 0b10010},
+		{Bits_REG, 3, 0, 0},
+		{Bits_MOD, 0, 0, 0b11},
+		{Bits_RM, 0, 0, 0b000},
+		{Bits_W, 0, 0, 1},
+	}},
+
+	// IN / OUT
+	{Op_in, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1110010},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_DataUnsigned, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b000},
+		{Bits_D, 0, 0, 1},
+	}},
+	{Op_in, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1110110},
+		{Bits_W, 1, 0, 0},
+		{Bits_REG, 0, 0, 0b000},
+		{Bits_MOD, 0, 0, 0b11},
+		{Bits_RM, 0, 0, 0b010},
+		{Bits_RMRegAlwaysW, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+	}},
+	{Op_out, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1110011},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_DataUnsigned, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b000},
+		{Bits_D, 0, 0, 0},
+	}},
+	{Op_out, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1110111},
+		{Bits_W, 1, 0, 0},
+		{Bits_REG, 0, 0, 0b000},
+		{Bits_MOD, 0, 0, 0b11},
+		{Bits_RM, 0, 0, 0b010},
+		{Bits_RMRegAlwaysW, 0, 0, 1},
+		{Bits_D, 0, 0, 0},
+	}},
+
+	// XLAT, LEA, LDS, LES, LAHF, SAHF, PUSHF, POPF
+	{Op_xlat, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11010111},
+	}},
+	{Op_lea, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10001101},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+		{Bits_MemoryOnly, 0, 0, 1},
+	}},
+	{Op_lds, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11000101},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+		{Bits_MemoryOnly, 0, 0, 1},
+	}},
+	{Op_les, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11000100},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+		{Bits_MemoryOnly, 0, 0, 1},
+	}},
+	{Op_lahf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011111},
+	}},
+	{Op_sahf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011110},
+	}},
+	{Op_pushf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011100},
+	}},
+	{Op_popf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011101},
+	}},
+
 	// ADD
 	{Op_add, []InstructionBits{
 		{Bits_Literal, 6, 0, 0b000000},
@@ -123,6 +245,57 @@ var InstructionTable8086 = []InstructionEncoding{
 		{Bits_D, 0, 0, 1},
 	}},
 
+	// ADC
+	{Op_adc, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b000100},
+		{Bits_D, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_adc, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b100000},
+		{Bits_S, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b010},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_adc, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b0001010},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// INC
+	{Op_inc, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111111},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b000},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_inc, []InstructionBits{
+		{Bits_Literal, 5, 0, 0b01000},
+		{Bits_REG, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// AAA, DAA
+	{Op_aaa, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b00110111},
+	}},
+	{Op_daa, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b00100111},
+	}},
+
 	// SUB
 	{Op_sub, []InstructionBits{
 		{Bits_Literal, 6, 0, 0b001010},
@@ -151,6 +324,58 @@ var InstructionTable8086 = []InstructionEncoding{
 		{Bits_D, 0, 0, 1},
 	}},
 
+	// SBB
+	{Op_sbb, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b000110},
+		{Bits_D, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_sbb, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b100000},
+		{Bits_S, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b011},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_sbb, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b0001110},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// DEC
+	{Op_dec, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111111},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b001},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_dec, []InstructionBits{
+		{Bits_Literal, 5, 0, 0b01001},
+		{Bits_REG, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// NEG
+	{Op_neg, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b011},
+		{Bits_RM, 3, 0, 0},
+	}},
+
 	// CMP
 	{Op_cmp, []InstructionBits{
 		{Bits_Literal, 6, 0, 0b001110},
@@ -179,6 +404,231 @@ var InstructionTable8086 = []InstructionEncoding{
 		{Bits_D, 0, 0, 1},
 	}},
 
+	// AAS, DAS, MUL, IMUL, AAM, DIV, IDIV, AAD, CBW, CWD
+	{Op_aas, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b00111111},
+	}},
+	{Op_das, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b00101111},
+	}},
+	{Op_mul, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b100},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_imul, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b101},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_aam, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11010100},
+		{Bits_Literal, 8, 0, 0b00001010},
+	}},
+	{Op_div, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b110},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_idiv, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b111},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_aad, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11010101},
+		{Bits_Literal, 8, 0, 0b00001010},
+	}},
+	{Op_cbw, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011000},
+	}},
+	{Op_cwd, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011001},
+	}},
+
+	// NOT, SHL/SAL, SHR, SAR, ROL, ROR, RCL, RCR
+	{Op_not, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b010},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_shl, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b100},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_shr, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b101},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_sar, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b111},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_rol, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b000},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_ror, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b001},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_rcl, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b010},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_rcr, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b110100},
+		{Bits_V, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b011},
+		{Bits_RM, 3, 0, 0},
+	}},
+
+	// AND
+	{Op_and, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b001000},
+		{Bits_D, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_and, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b100000},
+		{Bits_S, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b100},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_and, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b0010010},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// TEST
+	{Op_test, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1000010},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_test, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111011},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b000},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_test, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010100},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// OR
+	{Op_or, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b000010},
+		{Bits_D, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_or, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b100000},
+		{Bits_S, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b001},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_or, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b0000110},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
+	// XOR
+	{Op_xor, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b001100},
+		{Bits_D, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_REG, 3, 0, 0},
+		{Bits_RM, 3, 0, 0},
+	}},
+	{Op_xor, []InstructionBits{
+		{Bits_Literal, 6, 0, 0b100000},
+		{Bits_S, 1, 0, 0},
+		{Bits_W, 1, 0, 0},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b110},
+		{Bits_RM, 3, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+	}},
+	{Op_xor, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b0011010},
+		{Bits_W, 1, 0, 0},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_REG, 0, 0, 0b0},
+		{Bits_D, 0, 0, 1},
+	}},
+
 	// Jumps
 	{Op_je, []InstructionBits{
 		{Bits_Literal, 8, 0, 0b01110100},
@@ -281,94 +731,183 @@ var InstructionTable8086 = []InstructionEncoding{
 		{Bits_RelJMPDisp, 0, 0, 1},
 	}},
 
+	// INT, INT3, INTO, IRET
+	{Op_int, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001101},
+		{Bits_Data, 0, 0, 0},
+		{Bits_DataUnsigned, 0, 0, 1},
+	}},
+	{Op_int3, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001100},
+	}},
+	{Op_into, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001110},
+	}},
+	{Op_iret, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001111},
+	}},
+
+	// Processor control
+	{Op_clc, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111000},
+	}},
+	{Op_cmc, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11110101},
+	}},
+	{Op_stc, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111001},
+	}},
+	{Op_cld, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111100},
+	}},
+	{Op_std, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111101},
+	}},
+	{Op_cli, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111010},
+	}},
+	{Op_sti, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111011},
+	}},
+	{Op_hlt, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11110100},
+	}},
+	{Op_wait, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011011},
+	}},
+
+	// String manipulation
+	{Op_rep, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1111001},
+		{Bits_Z, 1, 0, 0},
+	}},
+	{Op_movs, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010010},
+		{Bits_W, 1, 0, 0},
+	}},
+	{Op_cmps, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010011},
+		{Bits_W, 1, 0, 0},
+	}},
+	{Op_scas, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010111},
+		{Bits_W, 1, 0, 0},
+	}},
+	{Op_lods, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010110},
+		{Bits_W, 1, 0, 0},
+	}},
+	{Op_stos, []InstructionBits{
+		{Bits_Literal, 7, 0, 0b1010101},
+		{Bits_W, 1, 0, 0},
+	}},
+
+	// CALL
+	{Op_call, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11101000},
+		{Bits_Disp, 0, 0, 0},
+		{Bits_DispAlwaysW, 0, 0, 1},
+		{Bits_RelJMPDisp, 0, 0, 1},
+	}},
+	{Op_call, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111111},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b010},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+	}},
+	{Op_call, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b10011010},
+		{Bits_Disp, 0, 0, 0},
+		{Bits_DispAlwaysW, 0, 0, 1},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_W, 0, 0, 1},
+		{Bits_Far, 0, 0, 1},
+	}},
+	{Op_call, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111111},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b011},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_Far, 0, 0, 1},
+		{Bits_MemoryOnly, 0, 0, 1},
+	}},
+
+	// JMP
+	{Op_jmp, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11101001},
+		{Bits_Disp, 0, 0, 0},
+		{Bits_DispAlwaysW, 0, 0, 1},
+		{Bits_RelJMPDisp, 0, 0, 1},
+	}},
+	{Op_jmp, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11101011},
+		{Bits_Disp, 0, 0, 0},
+		{Bits_RelJMPDisp, 0, 0, 1},
+	}},
+	{Op_jmp, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111111},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b100},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+	}},
+	{Op_jmp, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11101010},
+		{Bits_Disp, 0, 0, 0},
+		{Bits_DispAlwaysW, 0, 0, 1},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_W, 0, 0, 1},
+		{Bits_Far, 0, 0, 1},
+	}},
+	{Op_jmp, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11111111},
+		{Bits_MOD, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b101},
+		{Bits_RM, 3, 0, 0},
+		{Bits_W, 0, 0, 1},
+		{Bits_Far, 0, 0, 1},
+		{Bits_MemoryOnly, 0, 0, 1},
+	}},
+
+	// RET, RETF
+	{Op_ret, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11000011},
+	}},
+	{Op_ret, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11000010},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_DataUnsigned, 0, 0, 1},
+		{Bits_W, 0, 0, 1},
+	}},
+	{Op_retf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001011},
+	}},
+	{Op_retf, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11001010},
+		{Bits_Data, 0, 0, 0},
+		{Bits_WMakesDataW, 0, 0, 1},
+		{Bits_DataUnsigned, 0, 0, 1},
+		{Bits_W, 0, 0, 1},
+	}},
+
 	/*
-		{Op_pop, []InstructionBits{}},
-		{Op_xchg, []InstructionBits{}},
-		{Op_in, []InstructionBits{}},
-		{Op_out, []InstructionBits{}},
-		{Op_xlat, []InstructionBits{}},
-		{Op_lea, []InstructionBits{}},
-		{Op_lds, []InstructionBits{}},
-		{Op_les, []InstructionBits{}},
-		{Op_lahf, []InstructionBits{}},
-		{Op_sahf, []InstructionBits{}},
-		{Op_pushf, []InstructionBits{}},
-		{Op_popf, []InstructionBits{}},
-		{Op_add, []InstructionBits{}},
-		{Op_adc, []InstructionBits{}},
-		{Op_sub, []InstructionBits{}},
-		{Op_inc, []InstructionBits{}},
-		{Op_sbb, []InstructionBits{}},
-		{Op_aaa, []InstructionBits{}},
-		{Op_daa, []InstructionBits{}},
-		{Op_dec, []InstructionBits{}},
-		{Op_neg, []InstructionBits{}},
-		{Op_cmp, []InstructionBits{}},
-		{Op_aas, []InstructionBits{}},
-		{Op_das, []InstructionBits{}},
-		{Op_mul, []InstructionBits{}},
-		{Op_imul, []InstructionBits{}},
-		{Op_aam, []InstructionBits{}},
-		{Op_div, []InstructionBits{}},
-		{Op_idiv, []InstructionBits{}},
-		{Op_aad, []InstructionBits{}},
-		{Op_cbw, []InstructionBits{}},
-		{Op_cwd, []InstructionBits{}},
-
-		{Op_not, []InstructionBits{}},
-		{Op_shl, []InstructionBits{}},
-		{Op_shr, []InstructionBits{}},
-		{Op_sar, []InstructionBits{}},
-		{Op_rol, []InstructionBits{}},
-		{Op_ror, []InstructionBits{}},
-		{Op_rcl, []InstructionBits{}},
-		{Op_rcr, []InstructionBits{}},
-		{Op_and, []InstructionBits{}},
-		{Op_test, []InstructionBits{}},
-		{Op_or, []InstructionBits{}},
-		{Op_xor, []InstructionBits{}},
-		{Op_rep, []InstructionBits{}},
-		{Op_movs, []InstructionBits{}},
-		{Op_cmps, []InstructionBits{}},
-		{Op_scas, []InstructionBits{}},
-		{Op_lods, []InstructionBits{}},
-		{Op_stos, []InstructionBits{}},
-		{Op_call, []InstructionBits{}},
-		{Op_jmp, []InstructionBits{}},
-		{Op_ret, []InstructionBits{}},
-		{Op_je, []InstructionBits{}},
-		{Op_jl, []InstructionBits{}},
-		{Op_jle, []InstructionBits{}},
-		{Op_jb, []InstructionBits{}},
-		{Op_jbe, []InstructionBits{}},
-		{Op_jp, []InstructionBits{}},
-		{Op_js, []InstructionBits{}},
-		{Op_jne, []InstructionBits{}},
-		{Op_jnl, []InstructionBits{}},
-		{Op_jg, []InstructionBits{}},
-		{Op_jnb, []InstructionBits{}},
-		{Op_ja, []InstructionBits{}},
-		{Op_jnp, []InstructionBits{}},
-		{Op_jno, []InstructionBits{}},
-		{Op_jns, []InstructionBits{}},
-		{Op_loop, []InstructionBits{}},
-		{Op_loopz, []InstructionBits{}},
-		{Op_loopnz, []InstructionBits{}},
-		{Op_jcxz, []InstructionBits{}},
-		{Op_int, []InstructionBits{}},
-		{Op_int3, []InstructionBits{}},
-		{Op_into, []InstructionBits{}},
-		{Op_iret, []InstructionBits{}},
-		{Op_clc, []InstructionBits{}},
-		{Op_cmc, []InstructionBits{}},
-		{Op_stc, []InstructionBits{}},
-		{Op_cld, []InstructionBits{}},
-		{Op_std, []InstructionBits{}},
-		{Op_cli, []InstructionBits{}},
-		{Op_sti, []InstructionBits{}},
-		{Op_hlt, []InstructionBits{}},
-		{Op_wait, []InstructionBits{}},
-		{Op_esc, []InstructionBits{}},
-		{Op_lock, []InstructionBits{}},
-		{Op_segment, []InstructionBits{}},*/
+		{Op_esc, []InstructionBits{}},*/
+
+	// Lock prefix
+	{Op_lock, []InstructionBits{
+		{Bits_Literal, 8, 0, 0b11110000},
+	}},
+
+	// Segment override prefix
+	{Op_segment, []InstructionBits{
+		{Bits_Literal, 3, 0, 0b001},
+		{Bits_SR, 2, 0, 0},
+		{Bits_Literal, 3, 0, 0b110},
+		{Bits_D, 0, 0, 1},
+	}},
 }
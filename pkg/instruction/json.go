@@ -0,0 +1,304 @@
+package instruction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jsonInstruction is the schema an Instruction is written with. Field names
+// are part of the output format, so they must not change.
+type jsonInstruction struct {
+	Address   int           `json:"address"`
+	Bytes     string        `json:"bytes"`
+	Size      int           `json:"size"`
+	Mnemonic  string        `json:"mnemonic"`
+	Text      string        `json:"text"`
+	Prefixes  []string      `json:"prefixes"`
+	Operands  []jsonOperand `json:"operands"`
+	Width     int           `json:"width"`
+	Direction bool          `json:"direction"`
+	Mode      Mode          `json:"mode"`
+	Far       bool          `json:"far,omitempty"`
+	Target    *int          `json:"target,omitempty"`
+}
+
+type jsonOperand struct {
+	Type         string   `json:"type"`
+	Register     string   `json:"register,omitempty"`
+	Segment      string   `json:"segment,omitempty"`
+	Terms        []string `json:"terms,omitempty"`
+	Displacement *int     `json:"displacement,omitempty"`
+	Immediate    *int     `json:"immediate,omitempty"`
+	Relative     bool     `json:"relative,omitempty"`
+	Unsigned     bool     `json:"unsigned,omitempty"`
+	Wide         bool     `json:"wide,omitempty"`
+	Label        string   `json:"label,omitempty"`
+	SegmentValue *int     `json:"segment_value,omitempty"`
+	OffsetValue  *int     `json:"offset_value,omitempty"`
+}
+
+var operandTypeNames = map[OperandType]string{
+	Operand_Register:   "register",
+	Operand_Memory:     "memory",
+	Operand_Immediate:  "immediate",
+	Operand_FarPointer: "far_pointer",
+}
+
+func (i Instruction) MarshalJSON() ([]byte, error) {
+	j := jsonInstruction{
+		Address:   i.Address,
+		Bytes:     hex.EncodeToString(i.Bytes),
+		Size:      i.Size,
+		Mnemonic:  i.Op.String(),
+		Text:      i.String(),
+		Prefixes:  []string{},
+		Operands:  []jsonOperand{},
+		Width:     8,
+		Direction: i.Direction,
+		Mode:      i.Mode,
+		Far:       i.Flags&Inst_Far == Inst_Far,
+	}
+	if i.Wide {
+		j.Width = 16
+	}
+	j.Prefixes = prefixNames(i)
+	if target, ok := i.BranchTarget(); ok {
+		j.Target = &target
+	}
+
+	for _, op := range []InstructionOperand{i.Reg, i.RM} {
+		if op.Type == Operand_None {
+			continue
+		}
+		j.Operands = append(j.Operands, marshalOperand(op))
+	}
+	return json.Marshal(j)
+}
+
+var prefixByteNames = map[byte]string{
+	0xf0: "lock", 0xf2: "repne", 0xf3: "rep",
+	0x26: "es", 0x2e: "cs", 0x36: "ss", 0x3e: "ds",
+}
+
+// prefixNames lists one name per prefix byte, repeats included, so that
+// the prefixes can be told apart in Bytes. Instructions that were not
+// decoded from bytes get theirs from the flags.
+func prefixNames(i Instruction) []string {
+	names := []string{}
+	if len(i.Bytes) > 0 {
+		for _, b := range i.Prefixes {
+			names = append(names, prefixByteNames[b])
+		}
+		return names
+	}
+
+	if i.Flags&Inst_Lock == Inst_Lock {
+		names = append(names, "lock")
+	}
+	if i.Flags&Inst_Rep == Inst_Rep {
+		names = append(names, "rep")
+	}
+	if i.Flags&Inst_RepNE == Inst_RepNE {
+		names = append(names, "repne")
+	}
+	if i.Flags&Inst_Segment == Inst_Segment {
+		names = append(names, strings.ToLower(i.SegmentOverride.Name))
+	}
+	return names
+}
+
+func marshalOperand(op InstructionOperand) jsonOperand {
+	j := jsonOperand{Type: operandTypeNames[op.Type]}
+	switch op.Type {
+	case Operand_Register:
+		j.Register = strings.ToLower(op.Name)
+	case Operand_Memory:
+		j.Segment = strings.ToLower(op.Segment.Name)
+		for _, term := range op.Terms {
+			if term.Name != "" {
+				j.Terms = append(j.Terms, strings.ToLower(term.Name))
+			}
+		}
+		disp := op.DisplacementValue
+		j.Displacement = &disp
+		j.Label = op.DisplacementLabel
+	case Operand_Immediate:
+		value := op.Value
+		j.Immediate = &value
+		j.Relative = op.Immediate.Flags&Immediate_RelativeJumpDisplacement == Immediate_RelativeJumpDisplacement
+		j.Unsigned = op.Immediate.Flags&Immediate_Unsigned == Immediate_Unsigned
+		j.Wide = op.Immediate.Flags&int(Bits_W) == int(Bits_W)
+		j.Label = op.Label
+	case Operand_FarPointer:
+		segment, offset := op.SegmentValue, op.OffsetValue
+		j.SegmentValue = &segment
+		j.OffsetValue = &offset
+	}
+	return j
+}
+
+func (i *Instruction) UnmarshalJSON(data []byte) error {
+	var j jsonInstruction
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	op, ok := LookupOperation(j.Mnemonic)
+	if !ok {
+		return fmt.Errorf("unknown mnemonic %q", j.Mnemonic)
+	}
+	raw, err := hex.DecodeString(strings.ReplaceAll(j.Bytes, " ", ""))
+	if err != nil {
+		return fmt.Errorf("invalid bytes %q: %w", j.Bytes, err)
+	}
+	if len(j.Operands) > 2 {
+		return fmt.Errorf("%s has %d operands, at most 2 are supported", j.Mnemonic, len(j.Operands))
+	}
+
+	in := Instruction{
+		Op:        op,
+		Address:   j.Address,
+		Size:      j.Size,
+		Wide:      j.Width == 16,
+		Direction: j.Direction,
+		Mode:      j.Mode,
+	}
+	if len(raw) > 0 {
+		in.Bytes = raw
+		in.Prefixes = raw[:min(len(j.Prefixes), len(raw))]
+	}
+	if j.Far {
+		in.Flags |= Inst_Far
+	}
+	for _, prefix := range j.Prefixes {
+		switch prefix {
+		case "lock":
+			in.Flags |= Inst_Lock
+		case "rep":
+			in.Flags |= Inst_Rep
+		case "repne":
+			in.Flags |= Inst_RepNE
+		default:
+			reg, ok := LookupRegister(prefix)
+			if !ok || reg.Class != Register_Segment {
+				return fmt.Errorf("unknown prefix %q", prefix)
+			}
+			in.Flags |= Inst_Segment
+			in.SegmentOverride = reg
+		}
+	}
+
+	operands := [2]*InstructionOperand{&in.Reg, &in.RM}
+	for n, jo := range j.Operands {
+		op, err := unmarshalOperand(jo, in.Mode)
+		if err != nil {
+			return err
+		}
+		*operands[n] = op
+	}
+
+	*i = in
+	return nil
+}
+
+func unmarshalOperand(j jsonOperand, mode Mode) (InstructionOperand, error) {
+	switch j.Type {
+	case "register":
+		reg, ok := LookupRegister(j.Register)
+		if !ok {
+			return InstructionOperand{}, fmt.Errorf("unknown register %q", j.Register)
+		}
+		return InstructionOperand{Type: Operand_Register, Register: reg}, nil
+	case "memory":
+		var terms [2]string
+		if len(j.Terms) > 2 {
+			return InstructionOperand{}, fmt.Errorf("too many address terms %v", j.Terms)
+		}
+		copy(terms[:], j.Terms)
+		mem, ok := lookupMemory(mode, terms)
+		if !ok {
+			return InstructionOperand{}, fmt.Errorf("invalid effective address %v in mode %d", j.Terms, mode)
+		}
+		if j.Displacement != nil {
+			mem.DisplacementValue = *j.Displacement
+		}
+		if j.Segment != "" {
+			reg, ok := LookupRegister(j.Segment)
+			if !ok || reg.Class != Register_Segment {
+				return InstructionOperand{}, fmt.Errorf("unknown segment register %q", j.Segment)
+			}
+			mem.Segment = reg
+		}
+		mem.DisplacementLabel = j.Label
+		return mem, nil
+	case "immediate":
+		op := InstructionOperand{Type: Operand_Immediate}
+		if j.Immediate != nil {
+			op.Value = *j.Immediate
+		}
+		if j.Relative {
+			op.Immediate.Flags |= Immediate_RelativeJumpDisplacement
+		}
+		if j.Unsigned {
+			op.Immediate.Flags |= Immediate_Unsigned
+		}
+		if j.Wide {
+			op.Immediate.Flags |= int(Bits_W)
+		}
+		op.Label = j.Label
+		return op, nil
+	case "far_pointer":
+		if j.SegmentValue == nil || j.OffsetValue == nil {
+			return InstructionOperand{}, fmt.Errorf("far pointer without segment_value or offset_value")
+		}
+		return InstructionOperand{Type: Operand_FarPointer, FarPointer: FarPointer{SegmentValue: *j.SegmentValue, OffsetValue: *j.OffsetValue}}, nil
+	default:
+		return InstructionOperand{}, fmt.Errorf("unknown operand type %q", j.Type)
+	}
+}
+
+// ReadJSON reads back instructions written either as one JSON array or as
+// JSON Lines, one instruction per line.
+func ReadJSON(r io.Reader) ([]Instruction, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return []Instruction{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			break
+		}
+		br.ReadByte()
+	}
+
+	dec := json.NewDecoder(br)
+	if b, _ := br.Peek(1); b[0] == '[' {
+		instrs := []Instruction{}
+		if err := dec.Decode(&instrs); err != nil {
+			return nil, err
+		}
+		return instrs, nil
+	}
+
+	instrs := []Instruction{}
+	for {
+		var in Instruction
+		err := dec.Decode(&in)
+		if err == io.EOF {
+			return instrs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("instruction %d: %w", len(instrs), err)
+		}
+		instrs = append(instrs, in)
+	}
+}
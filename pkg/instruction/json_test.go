@@ -0,0 +1,89 @@
+package instruction_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+func TestInstruction_MarshalJSON(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+
+	var got []string
+	decodeAll(t, &it, []byte{0xf0, 0x26, 0x87, 0x47, 0xfe, 0xe2, 0xfe, 0x9a, 0x34, 0x12, 0x78, 0x56}, func(offset int, in instruction.Instruction, err error) {
+		if err != nil {
+			t.Fatalf("decoding at %d: %v", offset, err)
+		}
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatalf("Marshal(%s): %v", in, err)
+		}
+		got = append(got, string(data))
+	})
+
+	want := []string{
+		`{"address":0,"bytes":"f0268747fe","size":5,"mnemonic":"xchg","text":"lock xchg [es:bx - 2], ax","prefixes":["lock","es"],"operands":[{"type":"memory","segment":"es","terms":["bx"],"displacement":-2},{"type":"register","register":"ax"}],"width":16,"direction":false,"mode":1}`,
+		`{"address":5,"bytes":"e2fe","size":2,"mnemonic":"loop","text":"loop $+0","prefixes":[],"operands":[{"type":"immediate","immediate":-2,"relative":true}],"width":8,"direction":false,"mode":0,"target":5}`,
+		`{"address":7,"bytes":"9a34127856","size":5,"mnemonic":"call","text":"call 0x5678:0x1234","prefixes":[],"operands":[{"type":"far_pointer","segment_value":22136,"offset_value":4660}],"width":16,"direction":false,"mode":0,"far":true}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Marshal(). got=\n%s\nwant=\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReadJSON_RoundTrip(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+
+	instrs := []instruction.Instruction{}
+	decodeAll(t, &it, randomBinary(16*1024), func(offset int, in instruction.Instruction, err error) {
+		if err == nil {
+			instrs = append(instrs, in)
+		}
+	})
+
+	var array bytes.Buffer
+	if err := json.NewEncoder(&array).Encode(instrs); err != nil {
+		t.Fatalf("encoding array: %v", err)
+	}
+	var lines bytes.Buffer
+	enc := json.NewEncoder(&lines)
+	for _, in := range instrs {
+		if err := enc.Encode(in); err != nil {
+			t.Fatalf("encoding %s: %v", in, err)
+		}
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{"array": &array, "lines": &lines} {
+		got, err := instruction.ReadJSON(buf)
+		if err != nil {
+			t.Fatalf("ReadJSON(%s): %v", name, err)
+		}
+		if len(got) != len(instrs) {
+			t.Fatalf("ReadJSON(%s) read %d instructions, want %d", name, len(got), len(instrs))
+		}
+		for n := range instrs {
+			if !reflect.DeepEqual(got[n], instrs[n]) {
+				t.Fatalf("ReadJSON(%s) instruction %d. got=%#v want=%#v", name, n, got[n], instrs[n])
+			}
+		}
+	}
+}
+
+func TestReadJSON_Errors(t *testing.T) {
+	tests := []string{
+		`{"mnemonic":"movx"}`,
+		`{"mnemonic":"mov","operands":[{"type":"register","register":"ex"}]}`,
+		`{"mnemonic":"mov","mode":0,"operands":[{"type":"memory","terms":["si","bx"]}]}`,
+		`{"mnemonic":"mov","prefixes":["ax"]}`,
+		`{"mnemonic":"mov","bytes":"zz"}`,
+	}
+	for _, tt := range tests {
+		if _, err := instruction.ReadJSON(strings.NewReader(tt)); err == nil {
+			t.Errorf("ReadJSON(%s) expected an error", tt)
+		}
+	}
+}
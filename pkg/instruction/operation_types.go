@@ -64,6 +64,7 @@ const (
 	Op_call
 	Op_jmp
 	Op_ret
+	Op_retf
 	Op_je
 	Op_jl
 	Op_jle
@@ -100,6 +101,10 @@ const (
 	Op_esc
 	Op_lock
 	Op_segment
+
+	// Op_db is not an 8086 operation, it stands for a raw data byte the
+	// disassembler emits where no instruction could be decoded.
+	Op_db
 	Op_Count
 )
 
@@ -163,6 +168,7 @@ var OpcodeMnemonics = []string{
 	"call",
 	"jmp",
 	"ret",
+	"retf",
 	"je",
 	"jl",
 	"jle",
@@ -199,6 +205,8 @@ var OpcodeMnemonics = []string{
 	"esc",
 	"lock",
 	"segment",
+
+	"db",
 }
 
 func GetMnemonic(op OperationType) string {
@@ -217,3 +225,13 @@ func (op OperationType) String() string {
 	}
 	return mnemonic
 }
+
+// LookupOperation returns the operation whose mnemonic is name.
+func LookupOperation(name string) (OperationType, bool) {
+	for op := Op_None + 1; op < Op_Count; op++ {
+		if OpcodeMnemonics[op] == name {
+			return op, true
+		}
+	}
+	return Op_None, false
+}
@@ -1,280 +1,313 @@
 package instruction
 
-func (it InstructionTable) ResolveRegister(b byte, w bool) (InstructionOperand, bool) {
-	regs := map[bool]map[uint8]InstructionOperand{
-		true: {
-			0: {Register: Register{Name: "AX", Code: 0}, Type: Operand_Register},
-			1: {Register: Register{Name: "CX", Code: 1}, Type: Operand_Register},
-			2: {Register: Register{Name: "DX", Code: 2}, Type: Operand_Register},
-			3: {Register: Register{Name: "BX", Code: 3}, Type: Operand_Register},
-			4: {Register: Register{Name: "SP", Code: 4}, Type: Operand_Register},
-			5: {Register: Register{Name: "BP", Code: 5}, Type: Operand_Register},
-			6: {Register: Register{Name: "SI", Code: 6}, Type: Operand_Register},
-			7: {Register: Register{Name: "DI", Code: 7}, Type: Operand_Register},
-		},
-		false: {
-			0: {Register: Register{Name: "AL", Code: 0}, Type: Operand_Register},
-			1: {Register: Register{Name: "CL", Code: 1}, Type: Operand_Register},
-			2: {Register: Register{Name: "DL", Code: 2}, Type: Operand_Register},
-			3: {Register: Register{Name: "BL", Code: 3}, Type: Operand_Register},
-			4: {Register: Register{Name: "AH", Code: 4}, Type: Operand_Register},
-			5: {Register: Register{Name: "CH", Code: 5}, Type: Operand_Register},
-			6: {Register: Register{Name: "DH", Code: 6}, Type: Operand_Register},
-			7: {Register: Register{Name: "BH", Code: 7}, Type: Operand_Register},
-		},
-	}
+import (
+	"fmt"
+	"strings"
+)
 
-	subSet := regs[w]
+// registerTable holds the general registers indexed by W and then by their
+// 3-bit encoding.
+var registerTable = [2][8]InstructionOperand{
+	{
+		{Register: Register{Name: "AL", Code: 0}, Type: Operand_Register},
+		{Register: Register{Name: "CL", Code: 1}, Type: Operand_Register},
+		{Register: Register{Name: "DL", Code: 2}, Type: Operand_Register},
+		{Register: Register{Name: "BL", Code: 3}, Type: Operand_Register},
+		{Register: Register{Name: "AH", Code: 4}, Type: Operand_Register},
+		{Register: Register{Name: "CH", Code: 5}, Type: Operand_Register},
+		{Register: Register{Name: "DH", Code: 6}, Type: Operand_Register},
+		{Register: Register{Name: "BH", Code: 7}, Type: Operand_Register},
+	},
+	{
+		{Register: Register{Name: "AX", Code: 0}, Type: Operand_Register},
+		{Register: Register{Name: "CX", Code: 1}, Type: Operand_Register},
+		{Register: Register{Name: "DX", Code: 2}, Type: Operand_Register},
+		{Register: Register{Name: "BX", Code: 3}, Type: Operand_Register},
+		{Register: Register{Name: "SP", Code: 4}, Type: Operand_Register},
+		{Register: Register{Name: "BP", Code: 5}, Type: Operand_Register},
+		{Register: Register{Name: "SI", Code: 6}, Type: Operand_Register},
+		{Register: Register{Name: "DI", Code: 7}, Type: Operand_Register},
+	},
+}
 
-	reg, ok := subSet[b]
-	return reg, ok
+var segmentRegisterTable = [4]InstructionOperand{
+	{Register: Register{Name: "ES", Code: 0, Class: Register_Segment}, Type: Operand_Register},
+	{Register: Register{Name: "CS", Code: 1, Class: Register_Segment}, Type: Operand_Register},
+	{Register: Register{Name: "SS", Code: 2, Class: Register_Segment}, Type: Operand_Register},
+	{Register: Register{Name: "DS", Code: 3, Class: Register_Segment}, Type: Operand_Register},
 }
 
-func (it InstructionTable) ResolveMemoryAddress(mod Mode, rm byte) (InstructionOperand, bool) {
-	memTables := map[Mode]map[byte]InstructionOperand{
-		Memory: {
-			0b000: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "SI", Code: 6},
-					},
+// memoryTable holds the effective address expressions indexed by MOD (for
+// the three memory modes) and then by R/M. MOD 00 with R/M 110 is the direct
+// address, which has no terms.
+var memoryTable = [3][8]InstructionOperand{
+	{
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b001: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b010: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b011: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b100: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b101: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b110: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-				},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
 			},
-			0b111: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 0,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 0,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
 				},
 			},
 		},
-		Displ8: {
-			0b000: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "SI", Code: 6},
-					},
+	},
+	{
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b001: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b010: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b011: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b100: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b101: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b110: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
 				},
 			},
-			0b111: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 8,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 8,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
 				},
 			},
 		},
-
-		Displ16: {
-			0b000: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "SI", Code: 6},
-					},
+	},
+	{
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b001: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b010: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b011: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b100: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "SI", Code: 6},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "SI", Code: 6},
 				},
 			},
-			0b101: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "DI", Code: 7},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "DI", Code: 7},
 				},
 			},
-			0b110: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BP", Code: 5},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BP", Code: 5},
 				},
 			},
-			0b111: {
-				Type: Operand_Memory,
-				EffectiveAddressExpression: EffectiveAddressExpression{
-					Displacement: 16,
-					Terms: [2]Register{
-						{Name: "BX", Code: 3},
-					},
+		},
+		{
+			Type: Operand_Memory,
+			EffectiveAddressExpression: EffectiveAddressExpression{
+				Displacement: 16,
+				Terms: [2]Register{
+					{Name: "BX", Code: 3},
 				},
 			},
 		},
+	},
+}
+
+func (it InstructionTable) ResolveRegister(b byte, w bool) (InstructionOperand, bool) {
+	if b >= 8 {
+		return InstructionOperand{}, false
+	}
+	if w {
+		return registerTable[1][b], true
+	}
+	return registerTable[0][b], true
+}
+
+func (it InstructionTable) ResolveSegmentRegister(b byte) (InstructionOperand, bool) {
+	if b >= 4 {
+		return InstructionOperand{}, false
 	}
+	return segmentRegisterTable[b], true
+}
 
-	vals, ok := memTables[mod][rm]
-	return vals, ok
+func (it InstructionTable) ResolveMemoryAddress(mod Mode, rm byte) (InstructionOperand, bool) {
+	if mod > Displ16 || rm >= 8 {
+		return InstructionOperand{}, false
+	}
+	return memoryTable[mod][rm], true
 }
 
 func (it InstructionTable) ResolveImmediate(b int, flags int) (InstructionOperand, bool) {
 	var val int
-	if flags&int(Bits_W) == int(Bits_W) {
+	switch {
+	case flags&Immediate_Unsigned == Immediate_Unsigned && flags&int(Bits_W) == int(Bits_W):
+		val = int(uint16(b))
+	case flags&Immediate_Unsigned == Immediate_Unsigned:
+		val = int(uint8(b))
+	case flags&int(Bits_W) == int(Bits_W):
 		val = int(int16(b))
-	} else {
+	default:
 		val = int(int8(b))
 	}
 	return InstructionOperand{
@@ -285,3 +318,80 @@ func (it InstructionTable) ResolveImmediate(b int, flags int) (InstructionOperan
 		},
 	}, true
 }
+
+func (it InstructionTable) ResolveFarPointer(segment int, offset int) (InstructionOperand, bool) {
+	return InstructionOperand{
+		Type: Operand_FarPointer,
+		FarPointer: FarPointer{
+			SegmentValue: int(uint16(segment)),
+			OffsetValue:  int(uint16(offset)),
+		},
+	}, true
+}
+
+// LookupRegister returns the general or segment register called name, in
+// either case.
+func LookupRegister(name string) (Register, bool) {
+	name = strings.ToUpper(name)
+	for _, regs := range registerTable {
+		for _, reg := range regs {
+			if reg.Name == name {
+				return reg.Register, true
+			}
+		}
+	}
+	for _, reg := range segmentRegisterTable {
+		if reg.Name == name {
+			return reg.Register, true
+		}
+	}
+	return Register{}, false
+}
+
+// lookupMemory returns the effective address expression of the given mode
+// made of terms, which are empty for a direct address.
+func lookupMemory(mode Mode, terms [2]string) (InstructionOperand, bool) {
+	if mode > Displ16 {
+		return InstructionOperand{}, false
+	}
+	for _, mem := range memoryTable[mode] {
+		if strings.EqualFold(mem.Terms[0].Name, terms[0]) && strings.EqualFold(mem.Terms[1].Name, terms[1]) {
+			return mem, true
+		}
+	}
+	return InstructionOperand{}, false
+}
+
+// ResolveEffectiveAddress builds the memory operand [terms + disp], picking
+// the shortest mode that encodes it: no displacement when it is zero
+// (except for [bp], which has no such form), 8 bits when it fits in a
+// signed byte and 16 otherwise. Terms may be given in either order.
+func ResolveEffectiveAddress(terms [2]string, disp int) (InstructionOperand, Mode, error) {
+	if disp < -0x8000 || disp > 0xffff {
+		return InstructionOperand{}, 0, fmt.Errorf("displacement %d does not fit in 16 bits", disp)
+	}
+
+	if terms[0] == "" && terms[1] == "" {
+		mem, _ := lookupMemory(Memory, terms)
+		mem.DisplacementValue = disp & 0xffff
+		return mem, Memory, nil
+	}
+
+	mode := Displ16
+	switch {
+	case disp == 0 && !(strings.EqualFold(terms[0], "bp") && terms[1] == "") && !(strings.EqualFold(terms[1], "bp") && terms[0] == ""):
+		mode = Memory
+	case disp >= -128 && disp <= 127:
+		mode = Displ8
+	}
+
+	mem, ok := lookupMemory(mode, terms)
+	if !ok {
+		mem, ok = lookupMemory(mode, [2]string{terms[1], terms[0]})
+	}
+	if !ok || (mode == Memory && mem.Terms[0].Name == "") {
+		return InstructionOperand{}, 0, fmt.Errorf("invalid effective address [%s + %s]", terms[0], terms[1])
+	}
+	mem.DisplacementValue = int(int16(disp))
+	return mem, mode, nil
+}
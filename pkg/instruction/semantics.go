@@ -0,0 +1,427 @@
+package instruction
+
+import "strings"
+
+// RegisterMask is a set of 16-bit registers. An access to AL or AH counts
+// as an access to AX.
+type RegisterMask uint16
+
+const (
+	RegMask_AX RegisterMask = 1 << iota
+	RegMask_CX
+	RegMask_DX
+	RegMask_BX
+	RegMask_SP
+	RegMask_BP
+	RegMask_SI
+	RegMask_DI
+	RegMask_ES
+	RegMask_CS
+	RegMask_SS
+	RegMask_DS
+)
+
+var registerMaskNames = []string{"ax", "cx", "dx", "bx", "sp", "bp", "si", "di", "es", "cs", "ss", "ds"}
+
+func (m RegisterMask) String() string {
+	names := []string{}
+	for n, name := range registerMaskNames {
+		if m&(1<<n) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// RegisterMaskOf returns the mask of the 16-bit register reg is part of.
+func RegisterMaskOf(reg Register) RegisterMask {
+	if reg.Name == "" {
+		return 0
+	}
+	if reg.Class == Register_Segment {
+		return RegMask_ES << reg.Code
+	}
+	if len(reg.Name) == 2 && (reg.Name[1] == 'L' || reg.Name[1] == 'H') {
+		return 1 << (reg.Code & 0b11)
+	}
+	return 1 << reg.Code
+}
+
+// CPUFlags are the bits of the FLAGS register, at their 8086 positions.
+type CPUFlags uint16
+
+const (
+	Flag_CF CPUFlags = 1 << 0
+	Flag_PF CPUFlags = 1 << 2
+	Flag_AF CPUFlags = 1 << 4
+	Flag_ZF CPUFlags = 1 << 6
+	Flag_SF CPUFlags = 1 << 7
+	Flag_TF CPUFlags = 1 << 8
+	Flag_IF CPUFlags = 1 << 9
+	Flag_DF CPUFlags = 1 << 10
+	Flag_OF CPUFlags = 1 << 11
+
+	flags_Status = Flag_OF | Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF
+	flags_All    = flags_Status | Flag_TF | Flag_IF | Flag_DF
+)
+
+var cpuFlagNames = []struct {
+	flag CPUFlags
+	name string
+}{
+	{Flag_OF, "OF"}, {Flag_DF, "DF"}, {Flag_IF, "IF"}, {Flag_TF, "TF"},
+	{Flag_SF, "SF"}, {Flag_ZF, "ZF"}, {Flag_AF, "AF"}, {Flag_PF, "PF"}, {Flag_CF, "CF"},
+}
+
+func (f CPUFlags) String() string {
+	names := []string{}
+	for _, n := range cpuFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+type BranchKind int
+
+const (
+	Branch_None BranchKind = iota
+	Branch_Conditional
+	Branch_Unconditional
+	Branch_Call
+	Branch_Ret
+	Branch_Interrupt
+)
+
+func (b BranchKind) String() string {
+	switch b {
+	case Branch_Conditional:
+		return "conditional"
+	case Branch_Unconditional:
+		return "unconditional"
+	case Branch_Call:
+		return "call"
+	case Branch_Ret:
+		return "ret"
+	case Branch_Interrupt:
+		return "interrupt"
+	default:
+		return "none"
+	}
+}
+
+// Access is how an instruction uses one of its operands.
+type Access byte
+
+const (
+	Access_Read Access = 1 << iota
+	Access_Write
+	// Access_Address only computes the effective address, as lea does,
+	// without touching memory.
+	Access_Address
+
+	Access_None      Access = 0
+	Access_ReadWrite        = Access_Read | Access_Write
+)
+
+// Semantics describes everything an instruction reads and writes besides
+// IP, explicit operands and implicit ones alike.
+type Semantics struct {
+	RegsRead    RegisterMask
+	RegsWritten RegisterMask
+
+	FlagsRead      CPUFlags
+	FlagsWritten   CPUFlags
+	FlagsUndefined CPUFlags
+
+	MemoryRead  bool
+	MemoryWrite bool
+	// MemorySize is the number of bytes moved by the widest memory access.
+	MemorySize int
+
+	Branch BranchKind
+}
+
+// opSemantics is the entry of an operation in semanticsTable. The implicit
+// registers are indexed by W, byte forms first.
+type opSemantics struct {
+	first  Access
+	second Access
+
+	implicitRead  [2]RegisterMask
+	implicitWrite [2]RegisterMask
+
+	flagsRead      CPUFlags
+	flagsWritten   CPUFlags
+	flagsUndefined CPUFlags
+
+	// stack is the number of bytes pushed (positive) or popped (negative)
+	stack int
+	// source and destination are how a string operation accesses DS:SI
+	// and ES:DI
+	source      Access
+	destination Access
+
+	branch BranchKind
+}
+
+func both(m RegisterMask) [2]RegisterMask {
+	return [2]RegisterMask{m, m}
+}
+
+const (
+	arithFlags = Flag_OF | Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF
+	incFlags   = Flag_OF | Flag_SF | Flag_ZF | Flag_AF | Flag_PF
+	logicFlags = Flag_OF | Flag_SF | Flag_ZF | Flag_PF | Flag_CF
+	stackRegs  = RegMask_SP | RegMask_SS
+)
+
+var semanticsTable = [Op_Count]opSemantics{
+	Op_mov:   {first: Access_Write, second: Access_Read},
+	Op_push:  {first: Access_Read, implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), stack: 2},
+	Op_pop:   {first: Access_Write, implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), stack: -2},
+	Op_xchg:  {first: Access_ReadWrite, second: Access_ReadWrite},
+	Op_in:    {first: Access_Write, second: Access_Read},
+	Op_out:   {first: Access_Read, second: Access_Read},
+	Op_xlat:  {implicitRead: both(RegMask_AX | RegMask_BX), implicitWrite: both(RegMask_AX), source: Access_Read},
+	Op_lea:   {first: Access_Write, second: Access_Address},
+	Op_lds:   {first: Access_Write, second: Access_Read, implicitWrite: both(RegMask_DS)},
+	Op_les:   {first: Access_Write, second: Access_Read, implicitWrite: both(RegMask_ES)},
+	Op_lahf:  {implicitWrite: both(RegMask_AX), flagsRead: Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF},
+	Op_sahf:  {implicitRead: both(RegMask_AX), flagsWritten: Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF},
+	Op_pushf: {implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), flagsRead: flags_All, stack: 2},
+	Op_popf:  {implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), flagsWritten: flags_All, stack: -2},
+
+	Op_add: {first: Access_ReadWrite, second: Access_Read, flagsWritten: arithFlags},
+	Op_adc: {first: Access_ReadWrite, second: Access_Read, flagsRead: Flag_CF, flagsWritten: arithFlags},
+	Op_sub: {first: Access_ReadWrite, second: Access_Read, flagsWritten: arithFlags},
+	Op_sbb: {first: Access_ReadWrite, second: Access_Read, flagsRead: Flag_CF, flagsWritten: arithFlags},
+	Op_cmp: {first: Access_Read, second: Access_Read, flagsWritten: arithFlags},
+	Op_inc: {first: Access_ReadWrite, flagsWritten: incFlags},
+	Op_dec: {first: Access_ReadWrite, flagsWritten: incFlags},
+	Op_neg: {first: Access_ReadWrite, flagsWritten: arithFlags},
+	Op_aaa: {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsRead: Flag_AF, flagsWritten: Flag_AF | Flag_CF, flagsUndefined: Flag_OF | Flag_SF | Flag_ZF | Flag_PF},
+	Op_aas: {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsRead: Flag_AF, flagsWritten: Flag_AF | Flag_CF, flagsUndefined: Flag_OF | Flag_SF | Flag_ZF | Flag_PF},
+	Op_daa: {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsRead: Flag_AF | Flag_CF, flagsWritten: Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF, flagsUndefined: Flag_OF},
+	Op_das: {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsRead: Flag_AF | Flag_CF, flagsWritten: Flag_SF | Flag_ZF | Flag_AF | Flag_PF | Flag_CF, flagsUndefined: Flag_OF},
+
+	// byte forms work on AL into AX, word forms on AX into DX:AX
+	Op_mul:  {first: Access_Read, implicitRead: both(RegMask_AX), implicitWrite: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, flagsWritten: Flag_OF | Flag_CF, flagsUndefined: Flag_SF | Flag_ZF | Flag_AF | Flag_PF},
+	Op_imul: {first: Access_Read, implicitRead: both(RegMask_AX), implicitWrite: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, flagsWritten: Flag_OF | Flag_CF, flagsUndefined: Flag_SF | Flag_ZF | Flag_AF | Flag_PF},
+	Op_div:  {first: Access_Read, implicitRead: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, implicitWrite: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, flagsUndefined: flags_Status},
+	Op_idiv: {first: Access_Read, implicitRead: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, implicitWrite: [2]RegisterMask{RegMask_AX, RegMask_AX | RegMask_DX}, flagsUndefined: flags_Status},
+	Op_aam:  {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsWritten: Flag_SF | Flag_ZF | Flag_PF, flagsUndefined: Flag_OF | Flag_AF | Flag_CF},
+	Op_aad:  {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX), flagsWritten: Flag_SF | Flag_ZF | Flag_PF, flagsUndefined: Flag_OF | Flag_AF | Flag_CF},
+	Op_cbw:  {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_AX)},
+	Op_cwd:  {implicitRead: both(RegMask_AX), implicitWrite: both(RegMask_DX)},
+
+	Op_not: {first: Access_ReadWrite},
+	Op_shl: {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_shr: {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_sar: {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_rol: {first: Access_ReadWrite, second: Access_Read, flagsWritten: Flag_OF | Flag_CF},
+	Op_ror: {first: Access_ReadWrite, second: Access_Read, flagsWritten: Flag_OF | Flag_CF},
+	Op_rcl: {first: Access_ReadWrite, second: Access_Read, flagsRead: Flag_CF, flagsWritten: Flag_OF | Flag_CF},
+	Op_rcr: {first: Access_ReadWrite, second: Access_Read, flagsRead: Flag_CF, flagsWritten: Flag_OF | Flag_CF},
+
+	Op_and:  {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_test: {first: Access_Read, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_or:   {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+	Op_xor:  {first: Access_ReadWrite, second: Access_Read, flagsWritten: logicFlags, flagsUndefined: Flag_AF},
+
+	Op_movs: {source: Access_Read, destination: Access_Write, flagsRead: Flag_DF},
+	Op_cmps: {source: Access_Read, destination: Access_Read, flagsRead: Flag_DF, flagsWritten: arithFlags},
+	Op_scas: {destination: Access_Read, implicitRead: both(RegMask_AX), flagsRead: Flag_DF, flagsWritten: arithFlags},
+	Op_lods: {source: Access_Read, implicitWrite: both(RegMask_AX), flagsRead: Flag_DF},
+	Op_stos: {destination: Access_Write, implicitRead: both(RegMask_AX), flagsRead: Flag_DF},
+
+	Op_call: {first: Access_Read, implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), stack: 2, branch: Branch_Call},
+	Op_jmp:  {first: Access_Read, branch: Branch_Unconditional},
+	Op_ret:  {first: Access_Read, implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP), stack: -2, branch: Branch_Ret},
+	Op_retf: {first: Access_Read, implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP | RegMask_CS), stack: -4, branch: Branch_Ret},
+
+	Op_je:     {first: Access_Read, flagsRead: Flag_ZF, branch: Branch_Conditional},
+	Op_jne:    {first: Access_Read, flagsRead: Flag_ZF, branch: Branch_Conditional},
+	Op_jl:     {first: Access_Read, flagsRead: Flag_SF | Flag_OF, branch: Branch_Conditional},
+	Op_jnl:    {first: Access_Read, flagsRead: Flag_SF | Flag_OF, branch: Branch_Conditional},
+	Op_jle:    {first: Access_Read, flagsRead: Flag_ZF | Flag_SF | Flag_OF, branch: Branch_Conditional},
+	Op_jg:     {first: Access_Read, flagsRead: Flag_ZF | Flag_SF | Flag_OF, branch: Branch_Conditional},
+	Op_jb:     {first: Access_Read, flagsRead: Flag_CF, branch: Branch_Conditional},
+	Op_jnb:    {first: Access_Read, flagsRead: Flag_CF, branch: Branch_Conditional},
+	Op_jbe:    {first: Access_Read, flagsRead: Flag_CF | Flag_ZF, branch: Branch_Conditional},
+	Op_ja:     {first: Access_Read, flagsRead: Flag_CF | Flag_ZF, branch: Branch_Conditional},
+	Op_jo:     {first: Access_Read, flagsRead: Flag_OF, branch: Branch_Conditional},
+	Op_jno:    {first: Access_Read, flagsRead: Flag_OF, branch: Branch_Conditional},
+	Op_jp:     {first: Access_Read, flagsRead: Flag_PF, branch: Branch_Conditional},
+	Op_jnp:    {first: Access_Read, flagsRead: Flag_PF, branch: Branch_Conditional},
+	Op_js:     {first: Access_Read, flagsRead: Flag_SF, branch: Branch_Conditional},
+	Op_jns:    {first: Access_Read, flagsRead: Flag_SF, branch: Branch_Conditional},
+	Op_loop:   {first: Access_Read, implicitRead: both(RegMask_CX), implicitWrite: both(RegMask_CX), branch: Branch_Conditional},
+	Op_loopz:  {first: Access_Read, implicitRead: both(RegMask_CX), implicitWrite: both(RegMask_CX), flagsRead: Flag_ZF, branch: Branch_Conditional},
+	Op_loopnz: {first: Access_Read, implicitRead: both(RegMask_CX), implicitWrite: both(RegMask_CX), flagsRead: Flag_ZF, branch: Branch_Conditional},
+	Op_jcxz:   {first: Access_Read, implicitRead: both(RegMask_CX), branch: Branch_Conditional},
+
+	// interrupts push FLAGS, CS and IP and clear IF and TF
+	Op_int:  {first: Access_Read, implicitRead: both(stackRegs | RegMask_CS), implicitWrite: both(RegMask_SP | RegMask_CS), flagsRead: flags_All, flagsWritten: Flag_IF | Flag_TF, stack: 6, branch: Branch_Interrupt},
+	Op_int3: {implicitRead: both(stackRegs | RegMask_CS), implicitWrite: both(RegMask_SP | RegMask_CS), flagsRead: flags_All, flagsWritten: Flag_IF | Flag_TF, stack: 6, branch: Branch_Interrupt},
+	Op_into: {implicitRead: both(stackRegs | RegMask_CS), implicitWrite: both(RegMask_SP | RegMask_CS), flagsRead: flags_All, flagsWritten: Flag_IF | Flag_TF, stack: 6, branch: Branch_Interrupt},
+	Op_iret: {implicitRead: both(stackRegs), implicitWrite: both(RegMask_SP | RegMask_CS), flagsWritten: flags_All, stack: -6, branch: Branch_Ret},
+
+	Op_clc: {flagsWritten: Flag_CF},
+	Op_cmc: {flagsRead: Flag_CF, flagsWritten: Flag_CF},
+	Op_stc: {flagsWritten: Flag_CF},
+	Op_cld: {flagsWritten: Flag_DF},
+	Op_std: {flagsWritten: Flag_DF},
+	Op_cli: {flagsWritten: Flag_IF},
+	Op_sti: {flagsWritten: Flag_IF},
+}
+
+// Semantics returns what the instruction reads and writes, combining the
+// entry of its operation in semanticsTable with its actual operands,
+// width and prefixes.
+func (i Instruction) Semantics() Semantics {
+	if i.Op >= Op_Count {
+		return Semantics{}
+	}
+	entry := semanticsTable[i.Op]
+
+	w := 0
+	width := 1
+	if i.Wide {
+		w = 1
+		width = 2
+	}
+
+	s := Semantics{
+		RegsRead:       entry.implicitRead[w],
+		RegsWritten:    entry.implicitWrite[w],
+		FlagsRead:      entry.flagsRead,
+		FlagsWritten:   entry.flagsWritten,
+		FlagsUndefined: entry.flagsUndefined,
+		Branch:         entry.branch,
+	}
+
+	far := i.Flags&Inst_Far == Inst_Far || i.Reg.Type == Operand_FarPointer
+
+	for n, op := range []InstructionOperand{i.Reg, i.RM} {
+		access := i.OperandAccess(n)
+		if access == Access_None {
+			continue
+		}
+
+		switch op.Type {
+		case Operand_Register:
+			mask := RegisterMaskOf(op.Register)
+			if access&Access_Read != 0 {
+				s.RegsRead |= mask
+			}
+			if access&Access_Write != 0 {
+				s.RegsWritten |= mask
+			}
+		case Operand_Memory:
+			s.RegsRead |= RegisterMaskOf(op.Terms[0]) | RegisterMaskOf(op.Terms[1])
+			if access == Access_Address {
+				continue
+			}
+			s.RegsRead |= RegisterMaskOf(i.dataSegment(op))
+
+			size := width
+			if far || i.Op == Op_lds || i.Op == Op_les {
+				size = 4
+			}
+			s.MemorySize = max(s.MemorySize, size)
+			if access&Access_Read != 0 {
+				s.MemoryRead = true
+			}
+			if access&Access_Write != 0 {
+				s.MemoryWrite = true
+			}
+		}
+	}
+
+	// far transfers load CS, far calls push it along with IP
+	if far && (i.Op == Op_jmp || i.Op == Op_call) {
+		s.RegsWritten |= RegMask_CS
+		if i.Op == Op_call {
+			s.RegsRead |= RegMask_CS
+		}
+	}
+
+	if entry.stack != 0 {
+		size := entry.stack
+		if far && i.Op == Op_call {
+			size = 4
+		}
+		if size > 0 {
+			s.MemoryWrite = true
+		} else {
+			s.MemoryRead = true
+			size = -size
+		}
+		s.MemorySize = max(s.MemorySize, size)
+	}
+
+	if entry.source != Access_None {
+		segment := RegMask_DS
+		if i.Flags&Inst_Segment == Inst_Segment {
+			segment = RegisterMaskOf(i.SegmentOverride)
+		}
+		s.RegsRead |= segment
+		// xlat reads [bx + al], everything else walks SI
+		if i.Op != Op_xlat {
+			s.RegsRead |= RegMask_SI
+			s.RegsWritten |= RegMask_SI
+		}
+		s.MemoryRead = true
+		s.MemorySize = max(s.MemorySize, width)
+	}
+	if entry.destination != Access_None {
+		s.RegsRead |= RegMask_ES | RegMask_DI
+		s.RegsWritten |= RegMask_DI
+		if entry.destination&Access_Read != 0 {
+			s.MemoryRead = true
+		}
+		if entry.destination&Access_Write != 0 {
+			s.MemoryWrite = true
+		}
+		s.MemorySize = max(s.MemorySize, width)
+	}
+
+	// repeated string operations count down CX, repe and repne also
+	// test ZF after every compare
+	if i.IsString() && i.Flags&(Inst_Rep|Inst_RepNE) != 0 {
+		s.RegsRead |= RegMask_CX
+		s.RegsWritten |= RegMask_CX
+		if i.Op == Op_cmps || i.Op == Op_scas {
+			s.FlagsRead |= Flag_ZF
+		}
+	}
+
+	return s
+}
+
+// OperandAccess returns how the instruction uses its first (Reg, n = 0)
+// or second (RM, n = 1) operand.
+func (i Instruction) OperandAccess(n int) Access {
+	if i.Op >= Op_Count {
+		return Access_None
+	}
+	if n == 0 {
+		return semanticsTable[i.Op].first
+	}
+	return semanticsTable[i.Op].second
+}
+
+// dataSegment returns the segment register a memory operand is addressed
+// through: the override if there is one, SS for BP based addresses and DS
+// otherwise.
+func (i Instruction) dataSegment(op InstructionOperand) Register {
+	if op.Segment.Name != "" {
+		return op.Segment
+	}
+	if op.Terms[0].Name == "BP" {
+		return segmentRegisterTable[2].Register
+	}
+	return segmentRegisterTable[3].Register
+}
@@ -0,0 +1,113 @@
+package instruction_test
+
+import (
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+func TestInstruction_Semantics(t *testing.T) {
+	tests := []struct {
+		input []byte
+		str   string
+		want  instruction.Semantics
+	}{
+		{
+			input: []byte{0x01, 0x47, 0x04},
+			str:   "add [bx + 4], ax",
+			want: instruction.Semantics{
+				RegsRead:     instruction.RegMask_AX | instruction.RegMask_BX | instruction.RegMask_DS,
+				FlagsWritten: instruction.Flag_OF | instruction.Flag_SF | instruction.Flag_ZF | instruction.Flag_AF | instruction.Flag_PF | instruction.Flag_CF,
+				MemoryRead:   true, MemoryWrite: true, MemorySize: 2,
+			},
+		},
+		{
+			input: []byte{0xf6, 0xe3},
+			str:   "mul bl",
+			want: instruction.Semantics{
+				RegsRead:       instruction.RegMask_AX | instruction.RegMask_BX,
+				RegsWritten:    instruction.RegMask_AX,
+				FlagsWritten:   instruction.Flag_OF | instruction.Flag_CF,
+				FlagsUndefined: instruction.Flag_SF | instruction.Flag_ZF | instruction.Flag_AF | instruction.Flag_PF,
+			},
+		},
+		{
+			input: []byte{0xf7, 0x76, 0xfe},
+			str:   "div word [bp - 2]",
+			want: instruction.Semantics{
+				RegsRead:       instruction.RegMask_AX | instruction.RegMask_DX | instruction.RegMask_BP | instruction.RegMask_SS,
+				RegsWritten:    instruction.RegMask_AX | instruction.RegMask_DX,
+				FlagsUndefined: instruction.Flag_OF | instruction.Flag_SF | instruction.Flag_ZF | instruction.Flag_AF | instruction.Flag_PF | instruction.Flag_CF,
+				MemoryRead:     true, MemorySize: 2,
+			},
+		},
+		{
+			input: []byte{0xf3, 0xa6},
+			str:   "repe cmpsb",
+			want: instruction.Semantics{
+				RegsRead:     instruction.RegMask_CX | instruction.RegMask_SI | instruction.RegMask_DI | instruction.RegMask_ES | instruction.RegMask_DS,
+				RegsWritten:  instruction.RegMask_CX | instruction.RegMask_SI | instruction.RegMask_DI,
+				FlagsRead:    instruction.Flag_DF | instruction.Flag_ZF,
+				FlagsWritten: instruction.Flag_OF | instruction.Flag_SF | instruction.Flag_ZF | instruction.Flag_AF | instruction.Flag_PF | instruction.Flag_CF,
+				MemoryRead:   true, MemorySize: 1,
+			},
+		},
+		{
+			input: []byte{0x8d, 0x40, 0x02},
+			str:   "lea ax, [bx + si + 2]",
+			want: instruction.Semantics{
+				RegsRead:    instruction.RegMask_BX | instruction.RegMask_SI,
+				RegsWritten: instruction.RegMask_AX,
+			},
+		},
+		{
+			input: []byte{0xff, 0x1f},
+			str:   "call far [bx]",
+			want: instruction.Semantics{
+				RegsRead:    instruction.RegMask_BX | instruction.RegMask_DS | instruction.RegMask_SP | instruction.RegMask_SS | instruction.RegMask_CS,
+				RegsWritten: instruction.RegMask_SP | instruction.RegMask_CS,
+				MemoryRead:  true, MemoryWrite: true, MemorySize: 4,
+				Branch: instruction.Branch_Call,
+			},
+		},
+		{
+			input: []byte{0x76, 0x02},
+			str:   "jbe $+4",
+			want: instruction.Semantics{
+				FlagsRead: instruction.Flag_CF | instruction.Flag_ZF,
+				Branch:    instruction.Branch_Conditional,
+			},
+		},
+		{
+			input: []byte{0xcd, 0x21},
+			str:   "int 33",
+			want: instruction.Semantics{
+				RegsRead:     instruction.RegMask_SP | instruction.RegMask_SS | instruction.RegMask_CS,
+				RegsWritten:  instruction.RegMask_SP | instruction.RegMask_CS,
+				FlagsRead:    instruction.Flag_OF | instruction.Flag_DF | instruction.Flag_IF | instruction.Flag_TF | instruction.Flag_SF | instruction.Flag_ZF | instruction.Flag_AF | instruction.Flag_PF | instruction.Flag_CF,
+				FlagsWritten: instruction.Flag_IF | instruction.Flag_TF,
+				MemoryWrite:  true, MemorySize: 6,
+				Branch: instruction.Branch_Interrupt,
+			},
+		},
+	}
+
+	it := instruction.New8086InstructionTable()
+	for _, tt := range tests {
+		decodeAll(t, &it, tt.input, func(offset int, in instruction.Instruction, err error) {
+			if err != nil {
+				t.Fatalf("decoding % x: %v", tt.input, err)
+			}
+			if in.String() != tt.str {
+				t.Fatalf("decoded % x as %s, want %s", tt.input, in.String(), tt.str)
+			}
+			got := in.Semantics()
+			if got != tt.want {
+				t.Errorf("%s Semantics().\ngot  regs r=%s w=%s flags r=%s w=%s u=%s mem r=%v w=%v size=%d branch=%s\nwant regs r=%s w=%s flags r=%s w=%s u=%s mem r=%v w=%v size=%d branch=%s",
+					tt.str,
+					got.RegsRead, got.RegsWritten, got.FlagsRead, got.FlagsWritten, got.FlagsUndefined, got.MemoryRead, got.MemoryWrite, got.MemorySize, got.Branch,
+					tt.want.RegsRead, tt.want.RegsWritten, tt.want.FlagsRead, tt.want.FlagsWritten, tt.want.FlagsUndefined, tt.want.MemoryRead, tt.want.MemoryWrite, tt.want.MemorySize, tt.want.Branch)
+			}
+		})
+	}
+}
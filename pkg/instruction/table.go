@@ -8,19 +8,104 @@ import (
 )
 
 func New8086InstructionTable() InstructionTable {
-	return InstructionTable{
+	it := InstructionTable{
 		EncodingCount:           len(InstructionTable8086),
 		Encodings:               InstructionTable8086,
 		MaxInstructionByteCount: 15,
 	}
+	it.compileDispatch()
+	return it
 }
 
+// DecodeInstruction decodes the instruction whose first byte is the
+// reader's current byte. When the bytes do not form an instruction the
+// error is a *DecodeError.
 func (it *InstructionTable) DecodeInstruction(r *reader.Reader) (Instruction, error) {
+	var segment Register
+	var first Instruction
+	flags := 0
+	prefixSize := 0
+
+	start := r.SegmentOffset - 1
+
+	for {
+		instr, err := it.decodeOne(r)
+		if err != nil {
+			return Instruction{}, newDecodeError(r, start, err)
+		}
+		if instr.Op == Op_None {
+			return Instruction{}, newDecodeError(r, start, &DecodeError{Reason: DecodeError_UnknownOpcode})
+		}
+
+		// Prefixes are decoded like any other instruction but only modify
+		// the one that follows, so fold them in and keep going.
+		if instr.Op == Op_segment || instr.Op == Op_lock || instr.Op == Op_rep {
+			// a second prefix of a group would overwrite the first, which
+			// is returned on its own instead, for no byte to be lost
+			if flags&prefixGroup(instr.Op) != 0 {
+				r.Rewind(start + 1)
+				first.Address = r.Origin + start
+				first.Size = 1
+				first.Bytes = r.Data[start : start+1 : start+1]
+				first.Prefixes = first.Bytes[:0]
+				return first, nil
+			}
+			if prefixSize == 0 {
+				first = instr
+			}
+			if _, err := r.ReadByte(); err != nil {
+				return Instruction{}, newDecodeError(r, start, &DecodeError{Reason: DecodeError_Truncated})
+			}
+			switch instr.Op {
+			case Op_segment:
+				segment = instr.Reg.Register
+				flags |= Inst_Segment
+			case Op_lock:
+				flags |= Inst_Lock
+			case Op_rep:
+				flags |= instr.Flags & (Inst_Rep | Inst_RepNE)
+			}
+			prefixSize += instr.Size
+			continue
+		}
+
+		instr.Address = r.Origin + start
+		instr.Size += prefixSize
+		instr.Bytes = r.Data[start : start+instr.Size : start+instr.Size]
+		instr.Prefixes = instr.Bytes[:prefixSize]
+		instr.Flags |= flags
+		if flags&Inst_Segment == Inst_Segment {
+			instr.SegmentOverride = segment
+			if instr.Reg.Type == Operand_Memory {
+				instr.Reg.Segment = segment
+			}
+			if instr.RM.Type == Operand_Memory {
+				instr.RM.Segment = segment
+			}
+		}
+		return instr, nil
+	}
+}
+
+// prefixGroup returns the flags a prefix of op's group sets, both repeat
+// prefixes being one group.
+func prefixGroup(op OperationType) int {
+	switch op {
+	case Op_segment:
+		return Inst_Segment
+	case Op_lock:
+		return Inst_Lock
+	}
+	return Inst_Rep | Inst_RepNE
+}
+
+func (it *InstructionTable) decodeOne(r *reader.Reader) (Instruction, error) {
 	instr := Instruction{}
 
 	startingAddress := r.SegmentOffset
 
-	for _, encoding := range it.Encodings {
+	next, err := r.AccessData(0)
+	for _, encoding := range it.candidates(r.Curr, next, err == nil) {
 		in, err := it.TryDecode(encoding, r)
 		if err != nil {
 			return instr, err
@@ -41,18 +126,25 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 
 	bitIndx := 0
 
-	bits := make([]byte, Bits_Count)
-	has := make([]bool, Bits_Count)
+	var bits [Bits_Count]byte
+	var has [Bits_Count]bool
 
-	valid := true
 	for _, bit := range encoding.Bits {
 		if bit.Usage == Bits_End {
 			break
 		}
 		if bit.Usage == Bits_Literal {
+			// literals never straddle a byte boundary, so a literal
+			// following a fully consumed byte starts on the next one
+			if bitIndx >= 8 {
+				bitIndx -= 8
+				if _, err := r.ReadByte(); err != nil {
+					return Instruction{}, &DecodeError{Reason: DecodeError_Truncated}
+				}
+			}
 			mask := (byte(1<<bit.BitCount) - 1) << byte(8-bitIndx-int(bit.BitCount))
 			if (r.Curr & mask) != ((bit.Value << (8 - byte(bitIndx) - bit.BitCount)) & mask) {
-				valid = false
+				return Instruction{}, nil
 			}
 			bitIndx += int(bit.BitCount)
 		} else if bit.BitCount == 0 {
@@ -70,7 +162,9 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 			for range bit.BitCount {
 				if bitIndx >= 8 {
 					bitIndx -= 8
-					r.ReadByte()
+					if _, err := r.ReadByte(); err != nil {
+						return Instruction{}, &DecodeError{Reason: DecodeError_Truncated}
+					}
 				}
 				mask |= 1 << (8 - bitIndx - 1)
 				bitIndx++
@@ -81,15 +175,19 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 			has[bit.Usage] = true
 		}
 	}
-	if !valid {
-		return Instruction{}, nil
-	}
 	instr.Op = encoding.Op
 	mod := bits[Bits_MOD]
 	rm := bits[Bits_RM]
 	w := bits[Bits_W] == 1
 	s := bits[Bits_S] == 1
 	d := bits[Bits_D] == 1
+	v := bits[Bits_V] == 1
+
+	// the operand of lea, lds, les and the far indirect call and jmp is
+	// an address, a register in its place is not an instruction
+	if bits[Bits_MemoryOnly] == 1 && mod == byte(Reg) {
+		return Instruction{}, nil
+	}
 
 	hasDirectAddr := (mod == 0b00) && (rm == 0b110)
 	has[Bits_Disp] = ((has[Bits_Disp]) || (mod == 0b10) || hasDirectAddr)
@@ -97,6 +195,8 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 	var regOperand InstructionOperand
 	if has[Bits_REG] {
 		regOperand, _ = it.ResolveRegister(bits[Bits_REG], w)
+	} else if has[Bits_SR] {
+		regOperand, _ = it.ResolveSegmentRegister(bits[Bits_SR])
 	}
 
 	var modOperand InstructionOperand
@@ -108,17 +208,21 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 			// Memory mode
 			mem, _ := it.ResolveMemoryAddress(Mode(mod), bits[Bits_RM])
 			tmp := mem.DisplacementValue
+			// a direct address is always a 16-bit offset, whatever the
+			// width of the operand being addressed
 			if hasDirectAddr {
-				c, _ := r.ReadByte()
-				tmp = int(c)
-				if w {
-					c, _ := r.ReadByte()
-					tmp |= int(c) << 8
+				addr, err := readLittleEndian(r, 2)
+				if err != nil {
+					return Instruction{}, err
 				}
+				tmp = addr
 			}
-			for dis := 0; dis < mem.Displacement; dis += 8 {
-				c, _ := r.ReadByte()
-				tmp |= (int(c) << dis)
+			if mem.Displacement > 0 {
+				disp, err := readLittleEndian(r, mem.Displacement/8)
+				if err != nil {
+					return Instruction{}, err
+				}
+				tmp |= disp
 			}
 			switch mem.Displacement {
 			case 8:
@@ -132,18 +236,43 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 	}
 
 	if has[Bits_Data] && has[Bits_Disp] && !has[Bits_MOD] {
+		// direct intersegment pointer: the offset comes first, then the segment
+		offset, err := it.ParseDataValue(r, true, true, false)
+		if err != nil {
+			return Instruction{}, err
+		}
+		segment, err := it.ParseDataValue(r, true, true, false)
+		if err != nil {
+			return Instruction{}, err
+		}
+		modOperand, _ = it.ResolveFarPointer(segment, offset)
 	} else {
 		if has[Bits_RelJMPDisp] {
-			c, _ := r.ReadByte()
-			disp := int(int16(c))
-			imm, _ := it.ResolveImmediate(disp, Immediate_RelativeJumpDisplacement)
+			flags := Immediate_RelativeJumpDisplacement
+			size := 1
+			if bits[Bits_DispAlwaysW] == 1 {
+				size = 2
+				flags |= int(Bits_W)
+			}
+			disp, err := readLittleEndian(r, size)
+			if err != nil {
+				return Instruction{}, err
+			}
+			imm, _ := it.ResolveImmediate(disp, flags)
 			modOperand = imm
 		} else if has[Bits_Data] {
-			data := it.ParseDataValue(r, has[Bits_Data], w, s)
+			dataW := w && bits[Bits_WMakesDataW] == 1
+			data, err := it.ParseDataValue(r, has[Bits_Data], dataW, s)
+			if err != nil {
+				return Instruction{}, err
+			}
 			flags := int(0)
-			if bits[Bits_W] == 1 {
+			if dataW {
 				flags |= int(Bits_W)
 			}
+			if bits[Bits_DataUnsigned] == 1 {
+				flags |= Immediate_Unsigned
+			}
 
 			imm, _ := it.ResolveImmediate(data, flags)
 
@@ -157,6 +286,16 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 		}
 	}
 
+	// shifts and rotates take their count from CL when V is set,
+	// otherwise they shift by one
+	if has[Bits_V] {
+		if v {
+			regOperand, _ = it.ResolveRegister(0b001, false)
+		} else {
+			regOperand, _ = it.ResolveImmediate(1, 0)
+		}
+	}
+
 	if !d {
 		instr.RM = regOperand
 		instr.Reg = modOperand
@@ -165,6 +304,19 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 		instr.RM = modOperand
 	}
 
+	if bits[Bits_Far] == 1 {
+		instr.Flags |= Inst_Far
+	}
+
+	// Z selects between REP/REPE (1) and REPNE (0)
+	if has[Bits_Z] {
+		if bits[Bits_Z] == 1 {
+			instr.Flags |= Inst_Rep
+		} else {
+			instr.Flags |= Inst_RepNE
+		}
+	}
+
 	instr.Mode = Mode(mod)
 	instr.Direction = d
 	instr.Wide = w
@@ -175,28 +327,35 @@ func (it *InstructionTable) TryDecode(encoding InstructionEncoding, r *reader.Re
 	return instr, nil
 }
 
-func (it *InstructionTable) ParseDataValue(r *reader.Reader, exists, wide, signedExtended bool) int {
+// ParseDataValue reads an immediate, a word unless wide is false or the
+// byte is sign-extended. Running out of data is a truncation DecodeError.
+func (it *InstructionTable) ParseDataValue(r *reader.Reader, exists, wide, signedExtended bool) (int, error) {
 	if !exists {
-		return 0
+		return 0, nil
 	}
-	if wide {
-		dataL, _ := r.ReadByte()
+	if !wide || signedExtended {
+		data, err := readLittleEndian(r, 1)
 		if signedExtended {
-			return int(dataL)
+			return int(int8(data)), err
 		}
-		dataH, _ := r.ReadByte()
-
-		valInt := (int(dataH) << 8) | int(dataL)
-		return int(int16(valInt))
-	} else {
-		dataL, _ := r.ReadByte()
+		return data, err
+	}
+	data, err := readLittleEndian(r, 2)
+	return int(int16(data)), err
+}
 
-		if signedExtended {
-			return int(int8(dataL))
+// readLittleEndian reads a value of size bytes, low byte first. The
+// instruction is truncated when the data ends before it does.
+func readLittleEndian(r *reader.Reader, size int) (int, error) {
+	v := 0
+	for n := range size {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, &DecodeError{Reason: DecodeError_Truncated}
 		}
-
-		return int(dataL)
+		v |= int(c) << (8 * n)
 	}
+	return v, nil
 }
 
 func debugBits(bits []byte) {
@@ -237,6 +396,10 @@ func printBit(out bytes.Buffer, t InstructionBitsUsage, bit byte) bytes.Buffer {
 		out.WriteString("Bits_DispAlwaysW: ")
 	case Bits_Far:
 		out.WriteString("Bits_Far: ")
+	case Bits_DataUnsigned:
+		out.WriteString("Bits_DataUnsigned: ")
+	case Bits_MemoryOnly:
+		out.WriteString("Bits_MemoryOnly: ")
 	case Bits_Literal:
 		out.WriteString("Bits_Literal: ")
 	case Bits_MOD:
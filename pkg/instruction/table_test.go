@@ -0,0 +1,228 @@
+package instruction_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+// randomBinary returns n pseudo-random bytes, the same ones on every run.
+func randomBinary(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(8086)).Read(data)
+	return data
+}
+
+// validProgram is a mix of instruction forms that all decode cleanly,
+// registers, memory with and without displacements, immediates, prefixes,
+// jumps and far pointers.
+var validProgram = []byte{
+	0x89, 0xd9, 0x8b, 0x40, 0x04, 0x89, 0x8c, 0xd4, 0xfe, 0xc6, 0x03, 0x07, 0xc7, 0x85, 0x85, 0x03,
+	0x5b, 0x01, 0xa1, 0x10, 0x00, 0x83, 0xc6, 0xfe, 0x05, 0xe8, 0x03, 0x8e, 0xdb, 0x26, 0x8b, 0x40,
+	0x04, 0xf3, 0xa5, 0xf2, 0xae, 0xf0, 0x87, 0x07, 0xd1, 0xe0, 0xd2, 0x2f, 0xf6, 0x27, 0x75, 0xfe,
+	0xe8, 0x00, 0x01, 0xff, 0x1f, 0x9a, 0x78, 0x56, 0x34, 0x12, 0xc2, 0x04, 0x00, 0xcd, 0x21, 0x50,
+	0x5b, 0x9c, 0xd4, 0x0a, 0xc3,
+}
+
+// repeatProgram concatenates validProgram until it is at least n bytes long.
+func repeatProgram(n int) []byte {
+	data := make([]byte, 0, n+len(validProgram))
+	for len(data) < n {
+		data = append(data, validProgram...)
+	}
+	return data
+}
+
+// decodeAll walks data the way the lexer does, skipping a single byte on
+// every decode error, and calls fn for every instruction decoded.
+func decodeAll(tb testing.TB, it *instruction.InstructionTable, data []byte, fn func(offset int, in instruction.Instruction, err error)) {
+	tb.Helper()
+	r := reader.NewFromBytes(data)
+	if _, err := r.ReadByte(); err != nil {
+		return
+	}
+	for {
+		offset := r.SegmentOffset - 1
+		in, err := it.DecodeInstruction(r)
+		if err != nil {
+			var decErr *instruction.DecodeError
+			if !errors.As(err, &decErr) {
+				tb.Fatalf("DecodeInstruction() at %d returned %v", offset, err)
+			}
+			r.EndInstruction()
+			r.Rewind(offset + 1)
+		}
+		if fn != nil {
+			fn(offset, in, err)
+		}
+		r.BeginByteRecord()
+		if _, err := r.ReadByte(); err != nil {
+			return
+		}
+	}
+}
+
+// TestDecodeInstruction_MemoryOnly checks that the forms whose operand
+// must be an address do not decode with a register in its place.
+func TestDecodeInstruction_MemoryOnly(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"lea", []byte{0x8d, 0xc0}},
+		{"lds", []byte{0xc5, 0xd9}},
+		{"les", []byte{0xc4, 0xc1}},
+		{"far call", []byte{0xff, 0xd8}},
+		{"far jmp", []byte{0xff, 0xe8}},
+	}
+	for _, tt := range tests {
+		r := reader.NewFromBytes(tt.input)
+		r.ReadByte()
+		in, err := it.DecodeInstruction(r)
+		var decErr *instruction.DecodeError
+		if !errors.As(err, &decErr) || decErr.Reason != instruction.DecodeError_UnknownOpcode {
+			t.Errorf("%s. DecodeInstruction(% x) got=%v,%v want an unknown opcode", tt.name, tt.input, in, err)
+		}
+	}
+}
+
+func TestDecodeInstruction_DispatchMatchesLinear(t *testing.T) {
+	dispatched := instruction.New8086InstructionTable()
+	linear := instruction.InstructionTable{
+		Encodings:               instruction.InstructionTable8086,
+		EncodingCount:           len(instruction.InstructionTable8086),
+		MaxInstructionByteCount: 15,
+	}
+
+	data := randomBinary(64 * 1024)
+
+	type decoded struct {
+		offset int
+		str    string
+		size   int
+		err    string
+	}
+	collect := func(it *instruction.InstructionTable) []decoded {
+		out := []decoded{}
+		decodeAll(t, it, data, func(offset int, in instruction.Instruction, err error) {
+			d := decoded{offset: offset, str: in.String(), size: in.Size}
+			if err != nil {
+				d.err = err.Error()
+			}
+			out = append(out, d)
+		})
+		return out
+	}
+
+	want := collect(&linear)
+	got := collect(&dispatched)
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d instructions with dispatch, %d linearly", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("instruction %d differs. got=%+v want=%+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeInstruction_ZeroAllocs(t *testing.T) {
+	it := instruction.New8086InstructionTable()
+	r := reader.NewFromBytes(validProgram)
+	r.ReadByte()
+
+	// decode one instruction per run, starting over once the program has
+	// been walked, so that only the steady state is measured
+	decodeNext := func() {
+		if _, err := it.DecodeInstruction(r); err != nil {
+			t.Fatalf("validProgram does not decode: %v", err)
+		}
+		r.BeginByteRecord()
+		if _, err := r.ReadByte(); err != nil {
+			r.Rewind(1)
+		}
+	}
+	for range validProgram {
+		decodeNext()
+	}
+
+	allocs := testing.AllocsPerRun(1000, decodeNext)
+	if allocs != 0 {
+		t.Errorf("DecodeInstruction() allocated %v times per instruction, want 0", allocs)
+	}
+}
+
+func benchmarkDecode(b *testing.B, it instruction.InstructionTable, data []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for b.Loop() {
+		decodeAll(b, &it, data, nil)
+	}
+}
+
+func BenchmarkDecodeInstruction_Dispatch(b *testing.B) {
+	benchmarkDecode(b, instruction.New8086InstructionTable(), randomBinary(4<<20))
+}
+
+func BenchmarkDecodeInstruction_Linear(b *testing.B) {
+	benchmarkDecode(b, instruction.InstructionTable{
+		Encodings:               instruction.InstructionTable8086,
+		EncodingCount:           len(instruction.InstructionTable8086),
+		MaxInstructionByteCount: 15,
+	}, randomBinary(4<<20))
+}
+
+// BenchmarkDecodeInstruction_Valid only decodes well formed instructions, so
+// it reports the allocations of the decoder itself: there should be none.
+func BenchmarkDecodeInstruction_Valid(b *testing.B) {
+	benchmarkDecode(b, instruction.New8086InstructionTable(), repeatProgram(4<<20))
+}
+
+func BenchmarkResolveRegister(b *testing.B) {
+	it := instruction.New8086InstructionTable()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		it.ResolveRegister(byte(i&0b111), i&0b1000 != 0)
+	}
+}
+
+func BenchmarkResolveMemoryAddress(b *testing.B) {
+	it := instruction.New8086InstructionTable()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		it.ResolveMemoryAddress(instruction.Mode(i%3), byte(i&0b111))
+	}
+}
+
+// FuzzDecodeInstruction decodes arbitrary bytes. Every instruction has to
+// be made of the bytes it was decoded from and every failure has to be a
+// DecodeError pointing into the data, running out of bytes included.
+func FuzzDecodeInstruction(f *testing.F) {
+	f.Add(validProgram)
+	it := instruction.New8086InstructionTable()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeAll(t, &it, data, func(offset int, in instruction.Instruction, err error) {
+			if err != nil {
+				var decErr *instruction.DecodeError
+				errors.As(err, &decErr)
+				end := decErr.Offset + len(decErr.Bytes)
+				if decErr.Offset != offset || len(decErr.Bytes) == 0 || end > len(data) || !bytes.Equal(decErr.Bytes, data[offset:end]) {
+					t.Fatalf("% x at %d: DecodeError has offset %d and bytes % x", data, offset, decErr.Offset, decErr.Bytes)
+				}
+				return
+			}
+			if in.Size < 1 || offset+in.Size > len(data) {
+				t.Fatalf("% x at %d: %s is %d bytes", data, offset, in, in.Size)
+			}
+			if !bytes.Equal(in.Bytes, data[offset:offset+in.Size]) {
+				t.Fatalf("% x at %d: %s has bytes % x", data, offset, in, in.Bytes)
+			}
+		})
+	})
+}
@@ -1,7 +1,10 @@
 package lexer
 
 import (
+	"errors"
+
 	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/options"
 	"github.com/juanpablocruz/sim8086/pkg/reader"
 )
 
@@ -10,15 +13,23 @@ type Lexer struct {
 	position     int
 	ch           byte
 	isEOF        bool
+	flags        uint32
+	err          error
 
 	r     *reader.Reader
 	table instruction.InstructionTable
 }
 
 func New(r *reader.Reader) *Lexer {
+	return NewWithFlags(r, 0)
+}
+
+// NewWithFlags builds a lexer that honours the disassembly related
+// options.SimFlag_* bits, currently SimFlag_EmitUndecodable.
+func NewWithFlags(r *reader.Reader, flags uint32) *Lexer {
 	table := instruction.New8086InstructionTable()
 
-	l := &Lexer{r: r, table: table}
+	l := &Lexer{r: r, table: table, flags: flags}
 	l.readByte()
 	return l
 }
@@ -33,16 +44,35 @@ func (l *Lexer) readByte() {
 	l.isEOF = false
 }
 
+// NextInstruction returns the next decoded instruction, or an instruction
+// with Op_None once there is nothing more to decode. Err tells the end of
+// the data apart from a decoding failure.
 func (l *Lexer) NextInstruction() instruction.Instruction {
 	var tok instruction.Instruction
 
-	if l.isEOF {
+	if l.isEOF || l.err != nil {
 		return tok
 	}
 
 	in, err := l.table.DecodeInstruction(l.r)
 	if err != nil {
-		return tok
+		var decErr *instruction.DecodeError
+		if l.flags&options.SimFlag_EmitUndecodable == 0 || !errors.As(err, &decErr) {
+			l.err = err
+			return tok
+		}
+
+		// emit the first offending byte as data and resynchronise on the
+		// one right after it
+		in = instruction.Instruction{
+			Op:      instruction.Op_db,
+			Reg:     instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: int(decErr.Bytes[0]), Flags: instruction.Immediate_Unsigned}},
+			Size:    1,
+			Address: l.r.Origin + decErr.Offset,
+			Bytes:   decErr.Bytes[:1:1],
+		}
+		l.r.EndInstruction()
+		l.r.Rewind(decErr.Offset + 1)
 	}
 
 	l.r.BeginByteRecord()
@@ -50,3 +80,21 @@ func (l *Lexer) NextInstruction() instruction.Instruction {
 
 	return in
 }
+
+// All decodes the rest of the data, returning the instructions decoded up
+// to the error that stopped the lexer, if any.
+func (l *Lexer) All() ([]instruction.Instruction, error) {
+	instrs := []instruction.Instruction{}
+	for {
+		in := l.NextInstruction()
+		if in.Op == instruction.Op_None {
+			return instrs, l.Err()
+		}
+		instrs = append(instrs, in)
+	}
+}
+
+// Err returns the error that stopped the lexer, if any.
+func (l *Lexer) Err() error {
+	return l.err
+}
@@ -1,11 +1,15 @@
 package lexer_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/juanpablocruz/sim8086/pkg/instruction"
 	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/options"
 	"github.com/juanpablocruz/sim8086/pkg/reader"
 )
 
@@ -21,9 +25,7 @@ type instructionTest struct {
 func validateInstructions(t *testing.T, tests []instructionTest) {
 	t.Helper()
 	for _, tt := range tests {
-		r := reader.Reader{}
-		r.Data = tt.input
-		l := lexer.New(&r)
+		l := lexer.New(reader.NewFromBytes(tt.input))
 		i := 0
 		for i < len(tt.want) {
 			t.Run(tt.want[i].str, func(t *testing.T) {
@@ -2281,6 +2283,899 @@ func TestLexer_Listing41(t *testing.T) {
 	validateInstructions(t, tests)
 }
 
+func TestLexer_DataTransferArithmeticLogic(t *testing.T) {
+	tests := []instructionTest{
+		{
+			input: []byte{
+				0x8f, 0x07, 0x5b, 0x86, 0x07, 0xe4, 0xc8, 0xec, 0xe6, 0x2c, 0xd7, 0x8d, 0x5a, 0x04, 0xc5, 0x1e,
+				0x34, 0x12, 0x9f, 0x9c, 0x15, 0x34, 0x12, 0xff, 0x07, 0x40, 0x37, 0x80, 0x1f, 0x05, 0x4f, 0xf7,
+				0x1f, 0xf6, 0x27, 0xd4, 0x0a, 0xf7, 0x3f, 0x98, 0x99, 0xf6, 0xd0, 0xd1, 0xe0, 0xd2, 0x2f, 0x83,
+				0xe1, 0x0f, 0xf6, 0x07, 0x01, 0x31, 0xc0, 0x81, 0xf3, 0x34, 0x12, 0xcd, 0x21, 0xcf, 0xfc, 0xf4,
+				0x8a, 0x06, 0x34, 0x12, 0xff, 0x37, 0x83, 0xc6, 0xfe,
+			},
+			want: []testStruct{
+				{
+					str: "pop word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pop,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "pop bx",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pop,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "xchg [bx], al",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xchg,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+					},
+				},
+				{
+					str: "in al, 200",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_in,
+						Direction: true,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 200}},
+					},
+				},
+				{
+					str: "in al, dx",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_in,
+						Direction: true,
+						Wide:      false,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DX"}},
+					},
+				},
+				{
+					str: "out 44, al",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_out,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 44}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+					},
+				},
+				{
+					str: "xlat",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xlat,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "lea bx, [bp + si + 4]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_lea,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Displ8,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 8, DisplacementValue: 4, Terms: [2]instruction.Register{{Name: "BP"}, {Name: "SI"}}}},
+					},
+				},
+				{
+					str: "lds bx, [4660]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_lds,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 4660, Terms: [2]instruction.Register{}}},
+					},
+				},
+				{
+					str: "lahf",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_lahf,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "pushf",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pushf,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "adc ax, 4660",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_adc,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 4660}},
+					},
+				},
+				{
+					str: "inc word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_inc,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "inc ax",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_inc,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "aaa",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_aaa,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "sbb byte [bx], 5",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_sbb,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 5}},
+					},
+				},
+				{
+					str: "dec di",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_dec,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DI"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "neg word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_neg,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "mul byte [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mul,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "aam",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_aam,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "idiv word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_idiv,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "cbw",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_cbw,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "cwd",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_cwd,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "not al",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_not,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "shl ax, 1",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_shl,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 1}},
+					},
+				},
+				{
+					str: "shr byte [bx], cl",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_shr,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "CL"}},
+					},
+				},
+				{
+					str: "and cx, 15",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_and,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "CX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 15}},
+					},
+				},
+				{
+					str: "test byte [bx], 1",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_test,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 1}},
+					},
+				},
+				{
+					str: "xor ax, ax",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xor,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+					},
+				},
+				{
+					str: "xor bx, 4660",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xor,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 4660}},
+					},
+				},
+				{
+					str: "int 33",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_int,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 33}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "iret",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_iret,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "cld",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_cld,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "hlt",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_hlt,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "mov al, [4660]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: true,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 4660, Terms: [2]instruction.Register{}}},
+					},
+				},
+				{
+					str: "push word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_push,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "add si, -2",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_add,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "SI"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: -2}},
+					},
+				},
+			},
+		},
+	}
+	validateInstructions(t, tests)
+}
+
+func TestLexer_SegmentRegisters(t *testing.T) {
+	tests := []instructionTest{
+		{
+			input: []byte{0x8e, 0xdb, 0x06, 0x0e, 0x16, 0x1e, 0x07, 0x1f, 0x17, 0x8c, 0x07, 0x8e, 0x5f, 0x02},
+			want: []testStruct{
+				{
+					str: "mov ds, bx",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DS"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+					},
+				},
+				{
+					str: "push es",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_push,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "ES"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "push cs",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_push,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "CS"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "push ss",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_push,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "SS"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "push ds",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_push,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DS"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "pop es",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pop,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "ES"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "pop ds",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pop,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DS"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "pop ss",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_pop,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "SS"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "mov [bx], es",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "ES"}},
+					},
+				},
+				{
+					str: "mov ds, [bx + 2]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Displ8,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "DS"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 8, DisplacementValue: 2, Terms: [2]instruction.Register{{Name: "BX"}}}},
+					},
+				},
+			},
+		},
+	}
+	validateInstructions(t, tests)
+}
+
+func TestLexer_SegmentOverride(t *testing.T) {
+	tests := []instructionTest{
+		{
+			input: []byte{
+				0x26, 0x8b, 0x40, 0x04, 0x2e, 0xa1, 0x34, 0x12, 0x36, 0x88, 0x07, 0x3e, 0xd7, 0x26, 0x80, 0x3f,
+				0x01, 0x89, 0x07,
+			},
+			want: []testStruct{
+				{
+					str: "mov ax, [es:bx + si + 4]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Displ8,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 8, DisplacementValue: 4, Terms: [2]instruction.Register{{Name: "BX"}, {Name: "SI"}}}},
+					},
+				},
+				{
+					str: "mov ax, [cs:4660]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: true,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 4660, Terms: [2]instruction.Register{}}},
+					},
+				},
+				{
+					str: "mov [ss:bx], al",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AL"}},
+					},
+				},
+				{
+					str: "ds xlat",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xlat,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "cmp byte [es:bx], 1",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_cmp,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 1}},
+					},
+				},
+				{
+					str: "mov [bx], ax",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_mov,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+					},
+				},
+			},
+		},
+	}
+	validateInstructions(t, tests)
+}
+
+func TestLexer_StringPrefixes(t *testing.T) {
+	tests := []instructionTest{
+		{
+			input: []byte{0xf3, 0xa5, 0xf2, 0xae, 0xf0, 0x87, 0x07, 0xa4, 0xf3, 0xa6, 0xad, 0xaa},
+			want: []testStruct{
+				{
+					str: "rep movsw",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_movs,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "repne scasb",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_scas,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "lock xchg [bx], ax",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_xchg,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}},
+					},
+				},
+				{
+					str: "movsb",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_movs,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "repe cmpsb",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_cmps,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "lodsw",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_lods,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "stosb",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_stos,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+			},
+		},
+	}
+	validateInstructions(t, tests)
+}
+
+func TestLexer_ControlTransfer(t *testing.T) {
+	tests := []instructionTest{
+		{
+			input: []byte{
+				0xe8, 0x00, 0x01, 0xe9, 0xfd, 0xff, 0xeb, 0xfe, 0xff, 0xd3, 0xff, 0x17, 0xff, 0x1f, 0xff, 0x27,
+				0xff, 0x2e, 0x34, 0x12, 0x9a, 0x78, 0x56, 0x34, 0x12, 0xea, 0x00, 0x01, 0x00, 0xf0, 0xc3, 0xc2,
+				0x04, 0x00, 0xcb, 0xca, 0xfe, 0xff, 0x2e, 0xff, 0x2f,
+			},
+			want: []testStruct{
+				{
+					str: "call $+259",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_call,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 256}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp near $+0",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: -3}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp $+0",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: -2}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "call bx",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_call,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Reg,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "call word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_call,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "call far [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_call,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp word [bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp far [4660]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 4660, Terms: [2]instruction.Register{}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "call 0x1234:0x5678",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_call,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_FarPointer, FarPointer: instruction.FarPointer{SegmentValue: 0x1234, OffsetValue: 0x5678}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp 0xf000:0x0100",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_FarPointer, FarPointer: instruction.FarPointer{SegmentValue: 0xf000, OffsetValue: 0x0100}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "ret",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_ret,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "ret 4",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_ret,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 4}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "retf",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_retf,
+						Direction: false,
+						Wide:      false,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "retf 65534",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_retf,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Immediate, Immediate: instruction.Immediate{Value: 65534}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+				{
+					str: "jmp far [cs:bx]",
+					instruction: instruction.Instruction{
+						Op:        instruction.Op_jmp,
+						Direction: false,
+						Wide:      true,
+						Mode:      instruction.Memory,
+						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{Displacement: 0, DisplacementValue: 0, Terms: [2]instruction.Register{{Name: "BX"}}}},
+						RM:        instruction.InstructionOperand{},
+					},
+				},
+			},
+		},
+	}
+	validateInstructions(t, tests)
+}
+
 func TestLexer_Test(t *testing.T) {
 	tests := []instructionTest{
 		{
@@ -2289,7 +3184,7 @@ func TestLexer_Test(t *testing.T) {
 			},
 			want: []testStruct{
 				{
-					str: "cmp bx, [bx + si]",
+					str: "cmp bx, [bp]",
 					instruction: instruction.Instruction{
 						Op:        instruction.Op_cmp,
 						Direction: true,
@@ -2298,11 +3193,10 @@ func TestLexer_Test(t *testing.T) {
 						Reg:       instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "BX"}},
 						RM: instruction.InstructionOperand{
 							Type: instruction.Operand_Memory, EffectiveAddressExpression: instruction.EffectiveAddressExpression{
-								Displacement:      0,
+								Displacement:      8,
 								DisplacementValue: 0,
 								Terms: [2]instruction.Register{
-									{Name: "BX"},
-									{Name: "SI"},
+									{Name: "BP"},
 								},
 							},
 						},
@@ -2313,3 +3207,214 @@ func TestLexer_Test(t *testing.T) {
 	}
 	validateInstructions(t, tests)
 }
+
+func TestLexer_DecodeErrors(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		offset int
+		bytes  []byte
+		reason instruction.DecodeErrorReason
+	}{
+		{input: []byte{0x89, 0xd9, 0x60}, offset: 2, bytes: []byte{0x60}, reason: instruction.DecodeError_UnknownOpcode},
+		{input: []byte{0x89, 0xd9, 0x26, 0x60}, offset: 2, bytes: []byte{0x26, 0x60}, reason: instruction.DecodeError_UnknownOpcode},
+		{input: []byte{0x89, 0xd9, 0xff}, offset: 2, bytes: []byte{0xff}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0xd4}, offset: 0, bytes: []byte{0xd4}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0xf3}, offset: 0, bytes: []byte{0xf3}, reason: instruction.DecodeError_Truncated},
+		// the displacement or data runs past the end
+		{input: []byte{0x8b, 0x46}, offset: 0, bytes: []byte{0x8b, 0x46}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0x8b, 0x86, 0x01}, offset: 0, bytes: []byte{0x8b, 0x86, 0x01}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0x98, 0xa1, 0x10}, offset: 1, bytes: []byte{0xa1, 0x10}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0xb8, 0x01}, offset: 0, bytes: []byte{0xb8, 0x01}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0xc7, 0x06, 0x10, 0x00, 0x01}, offset: 0, bytes: []byte{0xc7, 0x06, 0x10, 0x00, 0x01}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0x75}, offset: 0, bytes: []byte{0x75}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0xe8, 0x00}, offset: 0, bytes: []byte{0xe8, 0x00}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0x9a, 0x78, 0x56, 0x34}, offset: 0, bytes: []byte{0x9a, 0x78, 0x56, 0x34}, reason: instruction.DecodeError_Truncated},
+		{input: []byte{0x26, 0x8b, 0x47}, offset: 0, bytes: []byte{0x26, 0x8b, 0x47}, reason: instruction.DecodeError_Truncated},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(reader.NewFromBytes(tt.input))
+		for l.NextInstruction().Op != instruction.Op_None {
+		}
+
+		var decErr *instruction.DecodeError
+		if !errors.As(l.Err(), &decErr) {
+			t.Fatalf("Err() for % x. got=%v want=*DecodeError", tt.input, l.Err())
+		}
+		if decErr.Offset != tt.offset {
+			t.Errorf("Err() invalid offset for % x. got=%d want=%d", tt.input, decErr.Offset, tt.offset)
+		}
+		if !bytes.Equal(decErr.Bytes, tt.bytes) {
+			t.Errorf("Err() invalid bytes for % x. got=% x want=% x", tt.input, decErr.Bytes, tt.bytes)
+		}
+		if decErr.Reason != tt.reason {
+			t.Errorf("Err() invalid reason for % x. got=%s want=%s", tt.input, decErr.Reason, tt.reason)
+		}
+	}
+}
+
+func TestLexer_EmitUndecodable(t *testing.T) {
+	l := lexer.NewWithFlags(reader.NewFromBytes([]byte{0x89, 0xd9, 0x60, 0x89, 0xd9, 0xff, 0xb8, 0x75}), options.SimFlag_EmitUndecodable)
+
+	// a truncated instruction leaves its first byte as data and the rest
+	// is decoded again
+	want := []string{"mov cx, bx", "db 0x60", "mov cx, bx", "db 0xff", "db 0xb8", "db 0x75"}
+	got := []string{}
+	for {
+		in := l.NextInstruction()
+		if in.Op == instruction.Op_None {
+			break
+		}
+		got = append(got, in.String())
+	}
+
+	if l.Err() != nil {
+		t.Errorf("Err() got=%v want=nil", l.Err())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("NextInstruction() got=%q want=%q", got, want)
+	}
+}
+
+func TestLexer_All(t *testing.T) {
+	instrs, err := lexer.New(reader.NewFromBytes([]byte{0x89, 0xd9, 0x98, 0x60})).All()
+	var decErr *instruction.DecodeError
+	if !errors.As(err, &decErr) || decErr.Offset != 3 {
+		t.Errorf("All() error. got=%v want a DecodeError at 3", err)
+	}
+	if len(instrs) != 2 || instrs[0].String() != "mov cx, bx" || instrs[1].String() != "cbw" {
+		t.Errorf("All(). got=%v want=[mov cx, bx cbw]", instrs)
+	}
+}
+
+func TestLexer_Origin(t *testing.T) {
+	r := reader.NewFromBytes([]byte{0x26, 0x8b, 0x40, 0x04, 0x75, 0xfa, 0xe8, 0xf7, 0xff, 0xe2, 0x00})
+	r.Origin = 0x100
+	l := lexer.New(r)
+
+	tests := []struct {
+		address int
+		target  int
+		branch  bool
+	}{
+		{address: 0x100},
+		{address: 0x104, target: 0x100, branch: true},
+		{address: 0x106, target: 0x100, branch: true},
+		{address: 0x109, target: 0x10b, branch: true},
+	}
+	for _, tt := range tests {
+		in := l.NextInstruction()
+		if in.Address != tt.address {
+			t.Errorf("%s invalid address. got=0x%04x want=0x%04x", in.String(), in.Address, tt.address)
+		}
+		target, ok := in.BranchTarget()
+		if ok != tt.branch || target != tt.target {
+			t.Errorf("%s invalid branch target. got=0x%04x (%v) want=0x%04x (%v)", in.String(), target, ok, tt.target, tt.branch)
+		}
+	}
+}
+
+func TestLexer_Bytes(t *testing.T) {
+	r := reader.NewFromBytes([]byte{0xf0, 0x26, 0x87, 0x07, 0x60, 0xf3, 0xa4, 0xb0, 0x01})
+	r.Origin = 0x100
+	l := lexer.NewWithFlags(r, options.SimFlag_EmitUndecodable)
+
+	tests := []struct {
+		str      string
+		address  int
+		bytes    []byte
+		prefixes []byte
+	}{
+		{str: "lock xchg [es:bx], ax", address: 0x100, bytes: []byte{0xf0, 0x26, 0x87, 0x07}, prefixes: []byte{0xf0, 0x26}},
+		{str: "db 0x60", address: 0x104, bytes: []byte{0x60}},
+		{str: "rep movsb", address: 0x105, bytes: []byte{0xf3, 0xa4}, prefixes: []byte{0xf3}},
+		{str: "mov al, 1", address: 0x107, bytes: []byte{0xb0, 0x01}},
+	}
+	for _, tt := range tests {
+		in := l.NextInstruction()
+		if in.String() != tt.str {
+			t.Errorf("invalid String representation. got=%s want=%s", in.String(), tt.str)
+		}
+		if in.Address != tt.address {
+			t.Errorf("%s invalid address. got=0x%04x want=0x%04x", tt.str, in.Address, tt.address)
+		}
+		if !bytes.Equal(in.Bytes, tt.bytes) {
+			t.Errorf("%s invalid bytes. got=% x want=% x", tt.str, in.Bytes, tt.bytes)
+		}
+		if !bytes.Equal(in.Prefixes, tt.prefixes) {
+			t.Errorf("%s invalid prefixes. got=% x want=% x", tt.str, in.Prefixes, tt.prefixes)
+		}
+	}
+}
+
+// TestLexer_RepeatedPrefixes checks that a second prefix of the same group
+// leaves the first one on its own rather than dropping it.
+func TestLexer_RepeatedPrefixes(t *testing.T) {
+	r := reader.NewFromBytes([]byte{0x26, 0x2e, 0x8b, 0x07, 0xf3, 0xf2, 0xa4, 0xf0, 0x26, 0xf0, 0x87, 0x07})
+	r.Origin = 0x100
+	l := lexer.New(r)
+
+	tests := []struct {
+		str      string
+		address  int
+		bytes    []byte
+		prefixes []byte
+	}{
+		{str: "es", address: 0x100, bytes: []byte{0x26}},
+		{str: "mov ax, [cs:bx]", address: 0x101, bytes: []byte{0x2e, 0x8b, 0x07}, prefixes: []byte{0x2e}},
+		{str: "rep", address: 0x104, bytes: []byte{0xf3}},
+		{str: "repne movsb", address: 0x105, bytes: []byte{0xf2, 0xa4}, prefixes: []byte{0xf2}},
+		{str: "lock", address: 0x107, bytes: []byte{0xf0}},
+		{str: "lock xchg [es:bx], ax", address: 0x108, bytes: []byte{0x26, 0xf0, 0x87, 0x07}, prefixes: []byte{0x26, 0xf0}},
+	}
+	for _, tt := range tests {
+		in := l.NextInstruction()
+		if in.String() != tt.str {
+			t.Errorf("invalid String representation. got=%s want=%s", in.String(), tt.str)
+		}
+		if in.Address != tt.address {
+			t.Errorf("%s invalid address. got=0x%04x want=0x%04x", tt.str, in.Address, tt.address)
+		}
+		if !bytes.Equal(in.Bytes, tt.bytes) {
+			t.Errorf("%s invalid bytes. got=% x want=% x", tt.str, in.Bytes, tt.bytes)
+		}
+		if !bytes.Equal(in.Prefixes, tt.prefixes) {
+			t.Errorf("%s invalid prefixes. got=% x want=% x", tt.str, in.Prefixes, tt.prefixes)
+		}
+	}
+	if in := l.NextInstruction(); in.Op != instruction.Op_None || l.Err() != nil {
+		t.Errorf("NextInstruction() at the end. got=%s,%v want nothing", in, l.Err())
+	}
+}
+
+// FuzzNextInstruction lexes arbitrary bytes with undecodable ones emitted
+// as data, which must account for every byte of the input, in order.
+func FuzzNextInstruction(f *testing.F) {
+	f.Add([]byte{0x89, 0xd9, 0x60, 0x89, 0xd9, 0xff, 0xb8, 0x75})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := reader.NewFromBytes(data)
+		r.Origin = 0x100
+		l := lexer.NewWithFlags(r, options.SimFlag_EmitUndecodable)
+
+		offset := 0
+		for {
+			in := l.NextInstruction()
+			if in.Op == instruction.Op_None {
+				break
+			}
+			if in.Address != r.Origin+offset || in.Size < 1 || offset+in.Size > len(data) {
+				t.Fatalf("% x: %s at 0x%04x, %d bytes, after %d bytes", data, in, in.Address, in.Size, offset)
+			}
+			if !bytes.Equal(in.Bytes, data[offset:offset+in.Size]) {
+				t.Fatalf("% x: %s has bytes % x, want % x", data, in, in.Bytes, data[offset:offset+in.Size])
+			}
+			offset += in.Size
+		}
+		if l.Err() != nil {
+			t.Fatalf("% x: Err() = %v", data, l.Err())
+		}
+		if offset != len(data) {
+			t.Fatalf("% x: lexed %d of %d bytes", data, offset, len(data))
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package memimage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ihex_Data                   = 0x00
+	ihex_EndOfFile              = 0x01
+	ihex_ExtendedSegmentAddress = 0x02
+	ihex_StartSegmentAddress    = 0x03
+	ihex_ExtendedLinearAddress  = 0x04
+	ihex_StartLinearAddress     = 0x05
+)
+
+// ParseIntelHex reads an Intel HEX file, honouring extended segment and
+// linear addresses and taking the start address records as the entry.
+func ParseIntelHex(r io.Reader) (*Image, error) {
+	chunks := []chunk{}
+	entry, hasEntry := 0, false
+	base := 0
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if text[0] != ':' {
+			return nil, fmt.Errorf("line %d: record does not start with ':'", line)
+		}
+		rec, err := hex.DecodeString(text[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(rec) < 5 || len(rec) != int(rec[0])+5 {
+			return nil, fmt.Errorf("line %d: record length does not match its byte count", line)
+		}
+		sum := byte(0)
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("line %d: checksum mismatch, got 0x%02x want 0x%02x", line, rec[len(rec)-1], rec[len(rec)-1]-sum)
+		}
+
+		address := int(rec[1])<<8 | int(rec[2])
+		data := rec[4 : len(rec)-1]
+		switch rec[3] {
+		case ihex_Data:
+			chunks = append(chunks, chunk{address: base + address, data: data, line: line})
+		case ihex_EndOfFile:
+			img, err := build(chunks)
+			if err != nil {
+				return nil, err
+			}
+			img.Entry, img.HasEntry = entry, hasEntry
+			return img, nil
+		case ihex_ExtendedSegmentAddress, ihex_ExtendedLinearAddress:
+			if len(data) != 2 {
+				return nil, fmt.Errorf("line %d: address record with %d bytes of data", line, len(data))
+			}
+			base = int(data[0])<<8 | int(data[1])
+			if rec[3] == ihex_ExtendedSegmentAddress {
+				base <<= 4
+			} else {
+				base <<= 16
+			}
+		case ihex_StartSegmentAddress:
+			if len(data) != 4 {
+				return nil, fmt.Errorf("line %d: start address record with %d bytes of data", line, len(data))
+			}
+			cs := int(data[0])<<8 | int(data[1])
+			ip := int(data[2])<<8 | int(data[3])
+			entry, hasEntry = cs<<4+ip, true
+		case ihex_StartLinearAddress:
+			if len(data) != 4 {
+				return nil, fmt.Errorf("line %d: start address record with %d bytes of data", line, len(data))
+			}
+			entry, hasEntry = int(data[0])<<24|int(data[1])<<16|int(data[2])<<8|int(data[3]), true
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type 0x%02x", line, rec[3])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("line %d: missing end of file record", line)
+}
@@ -0,0 +1,99 @@
+// Package memimage holds sparse, address-aware memory images as loaded from
+// Intel HEX and Motorola S-record files.
+package memimage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+// Segment is a contiguous run of bytes loaded at Address.
+type Segment struct {
+	Address int
+	Data    []byte
+}
+
+// End returns the first address past the segment.
+func (s Segment) End() int {
+	return s.Address + len(s.Data)
+}
+
+// Reader returns a reader over the segment whose origin is its address.
+func (s Segment) Reader() *reader.Reader {
+	r := reader.NewFromBytes(s.Data)
+	r.Origin = s.Address
+	return r
+}
+
+// Gap is a range of addresses between two segments that no record loads,
+// from Start up to but not including End.
+type Gap struct {
+	Start int
+	End   int
+}
+
+type Image struct {
+	// Segments are in address order, never overlap and never touch, two
+	// adjacent runs being merged into one.
+	Segments []Segment
+
+	// Entry is the start address given by the file, when HasEntry is set.
+	Entry    int
+	HasEntry bool
+}
+
+// chunk is the data of a single record before the image is assembled.
+type chunk struct {
+	address int
+	data    []byte
+	line    int
+}
+
+// build sorts and merges the chunks read from a file, rejecting records
+// that load the same address twice.
+func build(chunks []chunk) (*Image, error) {
+	sort.SliceStable(chunks, func(a, b int) bool {
+		return chunks[a].address < chunks[b].address
+	})
+
+	img := &Image{}
+	for _, c := range chunks {
+		if len(c.data) == 0 {
+			continue
+		}
+		last := len(img.Segments) - 1
+		if last >= 0 && c.address < img.Segments[last].End() {
+			return nil, fmt.Errorf("line %d: data at 0x%05x overlaps data loaded before", c.line, c.address)
+		}
+		if last >= 0 && c.address == img.Segments[last].End() {
+			img.Segments[last].Data = append(img.Segments[last].Data, c.data...)
+			continue
+		}
+		img.Segments = append(img.Segments, Segment{Address: c.address, Data: append([]byte{}, c.data...)})
+	}
+	return img, nil
+}
+
+// Gaps returns the unloaded ranges between the first and the last segment.
+func (img *Image) Gaps() []Gap {
+	gaps := []Gap{}
+	for n := 1; n < len(img.Segments); n++ {
+		gaps = append(gaps, Gap{Start: img.Segments[n-1].End(), End: img.Segments[n].Address})
+	}
+	return gaps
+}
+
+// Byte returns the byte loaded at address, false if it falls in a gap or
+// outside the image.
+func (img *Image) Byte(address int) (byte, bool) {
+	n := sort.Search(len(img.Segments), func(n int) bool {
+		return img.Segments[n].End() > address
+	})
+	if n == len(img.Segments) || address < img.Segments[n].Address {
+		return 0, false
+	}
+	s := img.Segments[n]
+	return s.Data[address-s.Address], true
+}
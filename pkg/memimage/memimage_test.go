@@ -0,0 +1,133 @@
+package memimage_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/memimage"
+)
+
+func ihexRecord(kind byte, address int, data ...byte) string {
+	rec := append([]byte{byte(len(data)), byte(address >> 8), byte(address), kind}, data...)
+	sum := byte(0)
+	for _, b := range rec {
+		sum += b
+	}
+	return fmt.Sprintf(":%X%02X\n", rec, -sum)
+}
+
+func srecRecord(kind byte, address []byte, data ...byte) string {
+	rec := append([]byte{byte(len(address) + len(data) + 1)}, address...)
+	rec = append(rec, data...)
+	sum := byte(0)
+	for _, b := range rec {
+		sum += b
+	}
+	return fmt.Sprintf("S%c%X%02X\n", kind, rec, ^sum)
+}
+
+func checkImage(t *testing.T, img *memimage.Image, want []memimage.Segment, gaps []memimage.Gap) {
+	t.Helper()
+	if len(img.Segments) != len(want) {
+		t.Fatalf("segments. got=%+v want=%+v", img.Segments, want)
+	}
+	for n, s := range img.Segments {
+		if s.Address != want[n].Address || !bytes.Equal(s.Data, want[n].Data) {
+			t.Errorf("segment %d. got=0x%x:% x want=0x%x:% x", n, s.Address, s.Data, want[n].Address, want[n].Data)
+		}
+	}
+	got := img.Gaps()
+	if len(got) != len(gaps) {
+		t.Fatalf("gaps. got=%+v want=%+v", got, gaps)
+	}
+	for n := range got {
+		if got[n] != gaps[n] {
+			t.Errorf("gap %d. got=%+v want=%+v", n, got[n], gaps[n])
+		}
+	}
+}
+
+func TestParseIntelHex(t *testing.T) {
+	src := ihexRecord(0x00, 0x0000, 0xb8, 0x01, 0x00) +
+		ihexRecord(0x00, 0x0003, 0xeb, 0xfe) +
+		ihexRecord(0x02, 0x0000, 0xf0, 0x00) + // segment 0xf000
+		ihexRecord(0x00, 0xfff0, 0xea, 0x00, 0x00, 0x00, 0xf0) +
+		ihexRecord(0x03, 0x0000, 0xf0, 0x00, 0xff, 0xf0) +
+		ihexRecord(0x01, 0x0000)
+
+	img, err := memimage.ParseIntelHex(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseIntelHex: %v", err)
+	}
+	checkImage(t, img,
+		[]memimage.Segment{
+			{Address: 0, Data: []byte{0xb8, 0x01, 0x00, 0xeb, 0xfe}},
+			{Address: 0xffff0, Data: []byte{0xea, 0x00, 0x00, 0x00, 0xf0}},
+		},
+		[]memimage.Gap{{Start: 5, End: 0xffff0}},
+	)
+	if !img.HasEntry || img.Entry != 0xffff0 {
+		t.Errorf("entry. got=0x%x (%v) want=0xffff0", img.Entry, img.HasEntry)
+	}
+	if b, ok := img.Byte(0xffff4); !ok || b != 0xf0 {
+		t.Errorf("Byte(0xffff4). got=0x%x,%v want=0xf0", b, ok)
+	}
+	if _, ok := img.Byte(0x10); ok {
+		t.Errorf("Byte(0x10) is in a gap, expected false")
+	}
+}
+
+func TestParseIntelHex_Errors(t *testing.T) {
+	good := ihexRecord(0x00, 0x0000, 0x90)
+	tests := map[string]string{
+		"checksum":      good[:len(good)-3] + "00\n" + ihexRecord(0x01, 0),
+		"no colon":      good[1:] + ihexRecord(0x01, 0),
+		"missing eof":   good,
+		"overlap":       good + ihexRecord(0x00, 0x0000, 0x90) + ihexRecord(0x01, 0),
+		"unknown type":  ihexRecord(0x07, 0) + ihexRecord(0x01, 0),
+		"byte count":    ":0200000090\n",
+		"bad extension": ihexRecord(0x04, 0, 0x01) + ihexRecord(0x01, 0),
+	}
+	for name, src := range tests {
+		if _, err := memimage.ParseIntelHex(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseSRecord(t *testing.T) {
+	src := srecRecord('0', []byte{0, 0}, 'h', 'i') +
+		srecRecord('1', []byte{0x01, 0x00}, 0xb8, 0x01, 0x00) +
+		srecRecord('2', []byte{0x00, 0x01, 0x03}, 0xeb, 0xfe) +
+		srecRecord('3', []byte{0x00, 0x00, 0x02, 0x00}, 0xf4) +
+		srecRecord('5', []byte{0x00, 0x03}) +
+		srecRecord('9', []byte{0x01, 0x00})
+
+	img, err := memimage.ParseSRecord(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseSRecord: %v", err)
+	}
+	checkImage(t, img,
+		[]memimage.Segment{
+			{Address: 0x100, Data: []byte{0xb8, 0x01, 0x00, 0xeb, 0xfe}},
+			{Address: 0x200, Data: []byte{0xf4}},
+		},
+		[]memimage.Gap{{Start: 0x105, End: 0x200}},
+	)
+	if !img.HasEntry || img.Entry != 0x100 {
+		t.Errorf("entry. got=0x%x (%v) want=0x100", img.Entry, img.HasEntry)
+	}
+
+	bad := srecRecord('1', []byte{0x01, 0x00}, 0x90)
+	bad = bad[:len(bad)-3] + "00\n"
+	if _, err := memimage.ParseSRecord(strings.NewReader(bad)); err == nil {
+		t.Errorf("bad checksum expected an error")
+	}
+
+	count := srecRecord('1', []byte{0x01, 0x00}, 0x90) + srecRecord('5', []byte{0x00, 0x02})
+	if _, err := memimage.ParseSRecord(strings.NewReader(count)); err == nil || err.Error() != "line 2: count record says 2 data records, found 1" {
+		t.Errorf("bad count. got=%v want=line 2: count record says 2 data records, found 1", err)
+	}
+}
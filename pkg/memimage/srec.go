@@ -0,0 +1,86 @@
+package memimage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseSRecord reads a Motorola S-record file. S1, S2 and S3 data records
+// are loaded, S7, S8 and S9 give the entry, S5 and S6 must count the data
+// records before them and header records are ignored.
+func ParseSRecord(r io.Reader) (*Image, error) {
+	chunks := []chunk{}
+	entry, hasEntry := 0, false
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(text) < 4 || text[0] != 'S' {
+			return nil, fmt.Errorf("line %d: record does not start with 'S'", line)
+		}
+		kind := text[1]
+		rec, err := hex.DecodeString(text[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(rec) < 1 || len(rec) != int(rec[0])+1 {
+			return nil, fmt.Errorf("line %d: record length does not match its byte count", line)
+		}
+		sum := byte(0)
+		for _, b := range rec[:len(rec)-1] {
+			sum += b
+		}
+		if ^sum != rec[len(rec)-1] {
+			return nil, fmt.Errorf("line %d: checksum mismatch, got 0x%02x want 0x%02x", line, rec[len(rec)-1], ^sum)
+		}
+
+		var addressSize int
+		switch kind {
+		case '0', '1', '5', '9':
+			addressSize = 2
+		case '2', '6', '8':
+			addressSize = 3
+		case '3', '7':
+			addressSize = 4
+		default:
+			return nil, fmt.Errorf("line %d: unknown record type S%c", line, kind)
+		}
+		if len(rec) < 2+addressSize {
+			return nil, fmt.Errorf("line %d: record too short for its address", line)
+		}
+		address := 0
+		for _, b := range rec[1 : 1+addressSize] {
+			address = address<<8 | int(b)
+		}
+		data := rec[1+addressSize : len(rec)-1]
+
+		switch kind {
+		case '1', '2', '3':
+			chunks = append(chunks, chunk{address: address, data: data, line: line})
+		case '5', '6':
+			if address != len(chunks) {
+				return nil, fmt.Errorf("line %d: count record says %d data records, found %d", line, address, len(chunks))
+			}
+		case '7', '8', '9':
+			entry, hasEntry = address, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	img, err := build(chunks)
+	if err != nil {
+		return nil, err
+	}
+	img.Entry, img.HasEntry = entry, hasEntry
+	return img, nil
+}
@@ -0,0 +1,133 @@
+// Package mz parses DOS MZ executables into their header, relocation table
+// and load module.
+package mz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Header is the fixed part of the MZ header, field for field.
+type Header struct {
+	Signature        [2]byte
+	LastPageBytes    uint16
+	Pages            uint16
+	Relocations      uint16
+	HeaderParagraphs uint16
+	MinAlloc         uint16
+	MaxAlloc         uint16
+	SS               uint16
+	SP               uint16
+	Checksum         uint16
+	IP               uint16
+	CS               uint16
+	RelocationOffset uint16
+	Overlay          uint16
+}
+
+// HeaderSize is the size of the fixed part of the header.
+const HeaderSize = 28
+
+// Relocation points at a word of the load module holding a segment value
+// that DOS adds the load segment to.
+type Relocation struct {
+	Offset  uint16
+	Segment uint16
+}
+
+// Linear returns the position of the relocated word in the load module.
+func (r Relocation) Linear() int {
+	return int(r.Segment)<<4 + int(r.Offset)
+}
+
+type File struct {
+	Header      Header
+	Relocations []Relocation
+	// LoadModule is the image DOS copies into memory, segment 0 being its
+	// first byte.
+	LoadModule []byte
+
+	relocated map[int]bool
+}
+
+var ErrNotMZ = errors.New("not an MZ executable")
+
+// IsMZ tells whether data starts with an MZ signature.
+func IsMZ(data []byte) bool {
+	return len(data) >= 2 && (string(data[:2]) == "MZ" || string(data[:2]) == "ZM")
+}
+
+// Parse reads an MZ executable. The load module is cut where the header
+// says the image ends, or at the end of data for files truncated short of
+// it.
+func Parse(data []byte) (*File, error) {
+	if !IsMZ(data) {
+		return nil, ErrNotMZ
+	}
+	if len(data) < HeaderSize {
+		return nil, fmt.Errorf("MZ header truncated at %d bytes", len(data))
+	}
+
+	f := &File{relocated: map[int]bool{}}
+	if err := binary.Read(bytes.NewReader(data[:HeaderSize]), binary.LittleEndian, &f.Header); err != nil {
+		return nil, err
+	}
+	h := f.Header
+
+	headerSize := int(h.HeaderParagraphs) << 4
+	if headerSize < HeaderSize || headerSize > len(data) {
+		return nil, fmt.Errorf("invalid MZ header size of %d paragraphs", h.HeaderParagraphs)
+	}
+
+	imageSize := int(h.Pages) * 512
+	if h.LastPageBytes != 0 {
+		imageSize -= 512 - int(h.LastPageBytes)
+	}
+	if imageSize < headerSize {
+		return nil, fmt.Errorf("MZ image of %d bytes is smaller than its header", imageSize)
+	}
+	imageSize = min(imageSize, len(data))
+	f.LoadModule = data[headerSize:imageSize]
+
+	table := int(h.RelocationOffset)
+	if table+int(h.Relocations)*4 > len(data) {
+		return nil, fmt.Errorf("MZ relocation table of %d entries at 0x%x runs past the end of the file", h.Relocations, table)
+	}
+	f.Relocations = make([]Relocation, h.Relocations)
+	if err := binary.Read(bytes.NewReader(data[table:table+int(h.Relocations)*4]), binary.LittleEndian, f.Relocations); err != nil {
+		return nil, err
+	}
+	for _, r := range f.Relocations {
+		f.relocated[r.Linear()] = true
+	}
+
+	return f, nil
+}
+
+// Entry returns the offset of the entry point in the load module.
+func (f *File) Entry() int {
+	return int(f.Header.CS)<<4 + int(f.Header.IP)
+}
+
+// RelocatedIn returns the relocated words that start within the size bytes
+// at linear position start of the load module, in ascending order.
+func (f *File) RelocatedIn(start int, size int) []int {
+	words := []int{}
+	for i := start; i < start+size; i++ {
+		if f.relocated[i] {
+			words = append(words, i)
+		}
+	}
+	return words
+}
+
+// Word returns the little endian word at linear position at of the load
+// module.
+func (f *File) Word(at int) (uint16, bool) {
+	if at < 0 || at+2 > len(f.LoadModule) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(f.LoadModule[at:]), true
+}
@@ -0,0 +1,74 @@
+package mz_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/mz"
+)
+
+// exe builds a two paragraph header with a single relocation followed by
+// code.
+func exe(code []byte) []byte {
+	size := 32 + len(code)
+	data := []byte{
+		'M', 'Z',
+		byte(size % 512), 0, byte(size/512 + 1), 0, // last page bytes, pages
+		1, 0, // relocations
+		2, 0, // header paragraphs
+		0, 0, 0xff, 0xff, // min and max alloc
+		0x02, 0x00, 0x00, 0x01, // ss:sp 0002:0100
+		0, 0, // checksum
+		0x03, 0x00, 0x00, 0x00, // cs:ip 0000:0003
+		0x1c, 0x00, // relocation table
+		0, 0, // overlay
+		0x04, 0x00, 0x00, 0x00, // relocation at 0000:0004
+	}
+	return append(data, code...)
+}
+
+func TestParse(t *testing.T) {
+	code := []byte{0x90, 0x90, 0x90, 0xb8, 0x01, 0x00, 0x8e, 0xd8}
+	f, err := mz.Parse(append(exe(code), 0xcc, 0xcc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	h := f.Header
+	if h.CS != 0 || h.IP != 3 || h.SS != 2 || h.SP != 0x100 || h.Relocations != 1 || h.HeaderParagraphs != 2 {
+		t.Errorf("header. got=%+v", h)
+	}
+	if f.Entry() != 3 {
+		t.Errorf("Entry(). got=%d want=3", f.Entry())
+	}
+	if string(f.LoadModule) != string(code) {
+		t.Errorf("LoadModule. got=% x want=% x", f.LoadModule, code)
+	}
+	if len(f.Relocations) != 1 || f.Relocations[0] != (mz.Relocation{Offset: 4, Segment: 0}) {
+		t.Errorf("Relocations. got=%+v", f.Relocations)
+	}
+	if got := f.RelocatedIn(3, 3); len(got) != 1 || got[0] != 4 {
+		t.Errorf("RelocatedIn(3, 3). got=%v want=[4]", got)
+	}
+	if got := f.RelocatedIn(6, 2); len(got) != 0 {
+		t.Errorf("RelocatedIn(6, 2). got=%v want=[]", got)
+	}
+	if w, ok := f.Word(4); !ok || w != 1 {
+		t.Errorf("Word(4). got=%d,%v want=1", w, ok)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	if _, err := mz.Parse([]byte{0xb8, 0x01, 0x00}); !errors.Is(err, mz.ErrNotMZ) {
+		t.Errorf("flat binary. got=%v want=%v", err, mz.ErrNotMZ)
+	}
+	if _, err := mz.Parse([]byte("MZ\x00\x00")); err == nil {
+		t.Errorf("truncated header expected an error")
+	}
+
+	bad := exe([]byte{0x90})
+	bad[6] = 0xff // relocation count past the end of the file
+	if _, err := mz.Parse(bad); err == nil {
+		t.Errorf("relocation table past the end expected an error")
+	}
+}
@@ -6,4 +6,7 @@ const (
 	SimFlag_DumpMemory      = 0x4
 	SimFlag_ExplainClocks   = 0x8
 	SimFlag_NoRegisterDiffs = 0x10
+	// Emit undecodable bytes as db and carry on with the next one instead
+	// of stopping the disassembly.
+	SimFlag_EmitUndecodable = 0x20
 )
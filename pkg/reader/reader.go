@@ -3,7 +3,9 @@ package reader
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -14,9 +16,13 @@ type Reader struct {
 	SegmentOffset int
 	Curr          byte
 
+	// Origin is the address Data[0] is loaded at, 0x100 for a .COM file.
+	Origin int
+
 	byteRecord []byte
 }
 
+// New reads the whole file at filepath into memory.
 func New(filepath string) (*Reader, error) {
 	r := Reader{}
 	f, err := r.Read(filepath)
@@ -35,12 +41,42 @@ func New(filepath string) (*Reader, error) {
 	return &r, nil
 }
 
+// NewFromBytes decodes straight from data, which is used as is, not copied.
+func NewFromBytes(data []byte) *Reader {
+	return &Reader{Data: data}
+}
+
+// NewFromReader drains src into memory, so it works with pipes and any
+// other stream that can only be read once.
+func NewFromReader(src io.Reader) (*Reader, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromBytes(data), nil
+}
+
+// NewFromReaderAt loads the first size bytes of src.
+func NewFromReaderAt(src io.ReaderAt, size int64) (*Reader, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("negative size %d", size)
+	}
+	data := make([]byte, size)
+	n, err := src.ReadAt(data, 0)
+	if err != nil && !(errors.Is(err, io.EOF) && int64(n) == size) {
+		return nil, err
+	}
+	return NewFromBytes(data), nil
+}
+
 func (r *Reader) Close() {
-	r.f.Close()
+	if r.f != nil {
+		r.f.Close()
+	}
 }
 
 func (r *Reader) BeginByteRecord() {
-	r.byteRecord = []byte{}
+	r.byteRecord = r.byteRecord[:0]
 }
 
 func (r *Reader) Read(filePath string) (*os.File, error) {
@@ -60,15 +96,9 @@ func (r *Reader) ReadNextBytes(file *os.File, number int) ([]byte, error) {
 }
 
 func (r *Reader) ReadFull(file *os.File) ([]byte, error) {
-	data := []byte{}
-
-	for {
-		d, err := r.GetNextBytes(file, 1)
-		if err != nil {
-			break
-		}
-
-		data = append(data, d...)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
 	}
 
 	r.Data = data
@@ -113,17 +143,35 @@ func (r *Reader) ReadByte() (byte, error) {
 	return r.Curr, nil
 }
 
+// Rewind moves the reader back so that the next byte read is the one at
+// offset, dropping the bytes after it from the instruction being recorded.
+// Offsets outside the data are clamped to it, leaving Curr 0 when there is
+// no byte before offset.
 func (r *Reader) Rewind(offset int) {
-	if len(r.byteRecord) > 0 {
-		for i := r.SegmentOffset; i > offset; i-- {
-			r.byteRecord = r.byteRecord[:len(r.byteRecord)-1]
-		}
-	}
+	offset = min(max(offset, 0), len(r.Data))
+	drop := min(max(r.SegmentOffset-offset, 0), len(r.byteRecord))
+	r.byteRecord = r.byteRecord[:len(r.byteRecord)-drop]
 	r.SegmentOffset = offset
-	r.Curr = r.Data[r.SegmentOffset-1]
+	r.Curr = 0
+	if offset > 0 {
+		r.Curr = r.Data[offset-1]
+	}
 	r.SegmentBase = r.SegmentOffset
 }
 
+// Seek makes the byte at offset the current one, the state DecodeInstruction
+// expects to find the reader in at the start of an instruction.
+func (r *Reader) Seek(offset int) error {
+	if offset < 0 || offset >= len(r.Data) {
+		return fmt.Errorf("index out of range %d", offset)
+	}
+	r.SegmentBase = offset
+	r.SegmentOffset = offset + 1
+	r.Curr = r.Data[offset]
+	r.byteRecord = append(r.byteRecord[:0], r.Curr)
+	return nil
+}
+
 func (r *Reader) Dump() string {
 	var binout bytes.Buffer
 	var out bytes.Buffer
@@ -136,12 +184,12 @@ func (r *Reader) Dump() string {
 }
 
 func (r *Reader) EndInstruction() {
-	r.byteRecord = []byte{}
+	r.byteRecord = r.byteRecord[:0]
 }
 
 func (r *Reader) EndInstructionAndPrint() {
 	r.PrintInstruction()
-	r.byteRecord = []byte{}
+	r.byteRecord = r.byteRecord[:0]
 }
 
 func (r *Reader) PrintInstruction() {
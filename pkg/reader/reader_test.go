@@ -0,0 +1,98 @@
+package reader_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+func TestReader_Constructors(t *testing.T) {
+	want := []byte{0x89, 0xd9, 0x88, 0xe5, 0x00, 0xff}
+
+	path := filepath.Join(t.TempDir(), "input.bin")
+	if err := os.WriteFile(path, want, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := reader.New(path)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer fromFile.Close()
+
+	// one byte per Read, the way a slow pipe would deliver it
+	fromReader, err := reader.NewFromReader(iotest.OneByteReader(bytes.NewReader(want)))
+	if err != nil {
+		t.Fatalf("NewFromReader() error: %v", err)
+	}
+
+	fromReaderAt, err := reader.NewFromReaderAt(bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatalf("NewFromReaderAt() error: %v", err)
+	}
+
+	tests := map[string]*reader.Reader{
+		"New":             fromFile,
+		"NewFromBytes":    reader.NewFromBytes(want),
+		"NewFromReader":   fromReader,
+		"NewFromReaderAt": fromReaderAt,
+	}
+	for name, r := range tests {
+		if !bytes.Equal(r.Data, want) {
+			t.Errorf("%s() invalid data. got=% x want=% x", name, r.Data, want)
+		}
+		for i, b := range want {
+			got, err := r.ReadByte()
+			if err != nil || got != b {
+				t.Errorf("%s() ReadByte %d. got=%02x (%v) want=%02x", name, i, got, err, b)
+			}
+		}
+		if _, err := r.ReadByte(); err == nil {
+			t.Errorf("%s() ReadByte past the end should fail", name)
+		}
+		r.Close()
+	}
+}
+
+func TestReader_NewFromReaderAtShort(t *testing.T) {
+	_, err := reader.NewFromReaderAt(bytes.NewReader([]byte{0x90}), 4)
+	if err != io.EOF {
+		t.Errorf("NewFromReaderAt() past the end. got=%v want=%v", err, io.EOF)
+	}
+	if _, err := reader.NewFromReaderAt(bytes.NewReader([]byte{0x90}), -1); err == nil || err.Error() != "negative size -1" {
+		t.Errorf("NewFromReaderAt() with a negative size. got=%v want=negative size -1", err)
+	}
+}
+
+func TestReader_Rewind(t *testing.T) {
+	tests := []struct {
+		offset int
+		next   byte
+		curr   byte
+		ok     bool
+	}{
+		{offset: 1, next: 0xd9, curr: 0x89, ok: true},
+		{offset: 0, next: 0x89, curr: 0x00, ok: true},
+		{offset: -5, next: 0x89, curr: 0x00, ok: true},
+		{offset: 3, curr: 0x88},
+		{offset: 10, curr: 0x88},
+	}
+	for _, tt := range tests {
+		r := reader.NewFromBytes([]byte{0x89, 0xd9, 0x88})
+		r.ReadByte()
+		r.ReadByte()
+		r.Rewind(tt.offset)
+		if r.Curr != tt.curr {
+			t.Errorf("Rewind(%d) Curr. got=%02x want=%02x", tt.offset, r.Curr, tt.curr)
+		}
+		got, err := r.ReadByte()
+		if (err == nil) != tt.ok || (tt.ok && got != tt.next) {
+			t.Errorf("Rewind(%d) then ReadByte. got=%02x (%v) want=%02x", tt.offset, got, err, tt.next)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package verify checks that the disassembly of a binary assembles back
+// into the very same bytes, which catches the printer writing something
+// the input did not say.
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/asm"
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/options"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+)
+
+// header is what the printed source starts with, the first instruction
+// being on line headerLines+1.
+const (
+	header      = "bits 16\norg 0x%04x\n"
+	headerLines = 2
+)
+
+// Mismatch is an instruction that did not reassemble into the bytes it was
+// decoded from.
+type Mismatch struct {
+	// Line is the line of the printed source the instruction is on, Text
+	// what it was printed as.
+	Line        int
+	Instruction instruction.Instruction
+	Text        string
+
+	// Got are the bytes the line assembled into and GotText what they
+	// decode to. Both are empty when the line did not assemble, Err
+	// saying why.
+	Got     []byte
+	GotText string
+	Err     error
+
+	// Equivalent is set when Got decodes to the same instruction as the
+	// input, only encoded another way, as NASM would have chosen it.
+	Equivalent bool
+}
+
+type Report struct {
+	// Instructions is the number of instructions checked, undecodable
+	// bytes included.
+	Instructions int
+	// Mismatches are in address order.
+	Mismatches []Mismatch
+}
+
+// First returns the first mismatch, or nil when there is none. When
+// equivalent is set, the instructions that only reassembled to another
+// encoding are left out.
+func (r *Report) First(equivalent bool) *Mismatch {
+	for n, m := range r.Mismatches {
+		if !equivalent || !m.Equivalent {
+			return &r.Mismatches[n]
+		}
+	}
+	return nil
+}
+
+// Equivalent counts the mismatches that are only another encoding.
+func (r *Report) Equivalent() int {
+	count := 0
+	for _, m := range r.Mismatches {
+		if m.Equivalent {
+			count++
+		}
+	}
+	return count
+}
+
+// Check disassembles data, loaded at origin, prints it in the NASM syntax
+// and assembles it back. Nothing the disassembler prints refers to another
+// line, so every line is assembled on its own at the address it was
+// decoded from, and compared with the bytes it came from. Bytes that do
+// not decode are printed as db and checked too.
+func Check(data []byte, origin int) (*Report, error) {
+	r := reader.NewFromBytes(data)
+	r.Origin = origin
+	instrs, err := lexer.NewWithFlags(r, options.SimFlag_EmitUndecodable).All()
+	if err != nil {
+		return nil, err
+	}
+	table := instruction.New8086InstructionTable()
+
+	report := &Report{}
+	for _, in := range instrs {
+		report.Instructions++
+
+		m := Mismatch{Line: headerLines + report.Instructions, Instruction: in, Text: in.String()}
+		bin, err := assembleLine(m.Text, in.Address)
+		if err != nil {
+			m.Err = err
+			report.Mismatches = append(report.Mismatches, m)
+			continue
+		}
+		if bytes.Equal(bin.Data, in.Bytes) {
+			continue
+		}
+		m.Got = bin.Data
+		if got, err := decodeOne(&table, bin.Data, in.Address); err == nil && got.Size == len(bin.Data) {
+			m.GotText = got.String()
+			m.Equivalent = sameInstruction(in, got)
+		}
+		report.Mismatches = append(report.Mismatches, m)
+	}
+	return report, nil
+}
+
+func assembleLine(text string, address int) (*asm.Binary, error) {
+	prog, err := asm.Parse(strings.NewReader(fmt.Sprintf(header, address) + text))
+	if err != nil {
+		return nil, err
+	}
+	return asm.Assemble(prog)
+}
+
+func decodeOne(table *instruction.InstructionTable, data []byte, address int) (instruction.Instruction, error) {
+	r := reader.NewFromBytes(data)
+	r.Origin = address
+	if _, err := r.ReadByte(); err != nil {
+		return instruction.Instruction{}, err
+	}
+	return table.DecodeInstruction(r)
+}
+
+// sameInstruction compares two decoded instructions leaving out what only
+// tells the encoding apart, the width of a displacement included.
+func sameInstruction(a, b instruction.Instruction) bool {
+	for _, in := range []*instruction.Instruction{&a, &b} {
+		in.Direction = false
+		in.Mode = 0
+		in.Size = 0
+		in.Bytes = nil
+		in.Prefixes = nil
+		for _, op := range []*instruction.InstructionOperand{&in.Reg, &in.RM} {
+			if op.Type == instruction.Operand_Memory {
+				op.Displacement = 0
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// WriteDiff prints the instruction with both byte sequences, carets under
+// the bytes that differ.
+func (m *Mismatch) WriteDiff(w io.Writer) error {
+	var out strings.Builder
+	in := m.Instruction
+	fmt.Fprintf(&out, "0x%04x  line %d: %s\n", in.Address, m.Line, m.Text)
+	if m.Err != nil {
+		fmt.Fprintf(&out, "  does not assemble: %s\n", m.Err)
+		_, err := io.WriteString(w, out.String())
+		return err
+	}
+
+	fmt.Fprintf(&out, "  %-12s % x\n", "input", in.Bytes)
+	fmt.Fprintf(&out, "  %-12s % x", "reassembled", m.Got)
+	switch {
+	case m.Equivalent:
+		out.WriteString("  ; another encoding of the same instruction")
+	case m.GotText == "":
+		out.WriteString("  ; does not decode")
+	case m.GotText != m.Text:
+		fmt.Fprintf(&out, "  ; reads back as %s", m.GotText)
+	default:
+		out.WriteString("  ; prints the same but decodes differently")
+	}
+	out.WriteString("\n")
+
+	carets := []string{}
+	for n := range max(len(in.Bytes), len(m.Got)) {
+		if n < len(in.Bytes) && n < len(m.Got) && in.Bytes[n] == m.Got[n] {
+			carets = append(carets, "  ")
+		} else {
+			carets = append(carets, "^^")
+		}
+	}
+	fmt.Fprintf(&out, "  %-12s %s\n", "", strings.TrimRight(strings.Join(carets, " "), " "))
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,118 @@
+package verify_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/verify"
+)
+
+// TestCheck_Listings runs the round trip over the part1 binaries, which
+// NASM assembled and so must come back byte for byte.
+func TestCheck_Listings(t *testing.T) {
+	listings, _ := filepath.Glob("../../part1/listing_*")
+	binaries := 0
+	for _, listing := range listings {
+		if filepath.Ext(listing) == ".asm" {
+			continue
+		}
+		binaries++
+		t.Run(filepath.Base(listing), func(t *testing.T) {
+			data, err := os.ReadFile(listing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := verify.Check(data, 0)
+			if err != nil {
+				t.Fatalf("Check() returned %v", err)
+			}
+			if m := report.First(false); m != nil {
+				var diff strings.Builder
+				m.WriteDiff(&diff)
+				t.Errorf("%d mismatches, the first:\n%s", len(report.Mismatches), diff.String())
+			}
+		})
+	}
+	if binaries == 0 {
+		t.Skip("no listings")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		equivalent bool
+		diff       string
+	}{
+		{
+			name:       "longer encoding",
+			input:      []byte{0xc7, 0xc0, 0x05, 0x00},
+			equivalent: true,
+			diff: "0x0100  line 3: mov ax, 5\n" +
+				"  input        c7 c0 05 00\n" +
+				"  reassembled  b8 05 00  ; another encoding of the same instruction\n" +
+				"               ^^ ^^ ^^ ^^\n",
+		},
+		{
+			name:       "word displacement that fits in a byte",
+			input:      []byte{0x8a, 0x99, 0x3c, 0x00},
+			equivalent: true,
+			diff: "0x0100  line 3: mov bl, [bx + di + 60]\n" +
+				"  input        8a 99 3c 00\n" +
+				"  reassembled  8a 59 3c  ; another encoding of the same instruction\n" +
+				"                  ^^    ^^\n",
+		},
+		{
+			name:       "zero byte displacement",
+			input:      []byte{0x8b, 0x40, 0x00},
+			equivalent: true,
+			diff: "0x0100  line 3: mov ax, [bx + si]\n" +
+				"  input        8b 40 00\n" +
+				"  reassembled  8b 00  ; another encoding of the same instruction\n" +
+				"                  ^^ ^^\n",
+		},
+		{
+			// a far call through a register is not an instruction, its
+			// bytes are printed as db and come back as they were
+			name:  "far call through a register",
+			input: []byte{0x90, 0xff, 0xd8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := verify.Check(tt.input, 0x100)
+			if err != nil {
+				t.Fatalf("Check() returned %v", err)
+			}
+			if tt.diff == "" {
+				if len(report.Mismatches) != 0 {
+					t.Errorf("Mismatches. got=%d want=0", len(report.Mismatches))
+				}
+				return
+			}
+			if len(report.Mismatches) != 1 {
+				t.Fatalf("Mismatches. got=%d want=1", len(report.Mismatches))
+			}
+			m := report.Mismatches[0]
+			if m.Equivalent != tt.equivalent {
+				t.Errorf("Equivalent. got=%v want=%v", m.Equivalent, tt.equivalent)
+			}
+			if got := report.First(false); got == nil {
+				t.Errorf("First(false). got=nil")
+			}
+			if got := report.First(true); (got == nil) != tt.equivalent {
+				t.Errorf("First(true). got=%v", got)
+			}
+			var diff strings.Builder
+			if err := m.WriteDiff(&diff); err != nil {
+				t.Fatal(err)
+			}
+			if diff.String() != tt.diff {
+				t.Errorf("WriteDiff().\ngot:\n%s\nwant:\n%s", diff.String(), tt.diff)
+			}
+		})
+	}
+}
@@ -33,20 +33,31 @@ func (c *Computer8086) ExecInstruction(in instruction.Instruction) ExecResult {
 }
 
 func (c *Computer8086) WriteN(reg instruction.InstructionOperand, rm instruction.InstructionOperand, size int) error {
+	var val ComputerRegister
+	if rm.Type == instruction.Operand_Memory {
+		val = ComputerRegister{Value: c.ReadMemory(c.PhysicalAddress(rm.EffectiveAddressExpression), size)}
+	} else {
+		val = c.AccessRegister(rm)
+	}
+
+	if reg.Type == instruction.Operand_Memory {
+		c.WriteMemory(c.PhysicalAddress(reg.EffectiveAddressExpression), size, val.Value)
+		return nil
+	}
+
 	regIdx := c.ResolveRegister(reg.Register)
 
 	if regIdx.Name == "" {
 		return fmt.Errorf("not found register: %s", reg.Name)
 	}
 
-	val := c.AccessRegister(rm)
-
+	curr := c.Registers[regIdx.Name]
 	if regIdx.Wide {
-		c.Registers[regIdx.Name] = val.Value
+		c.Registers[regIdx.Name] = val.Value & 0xffff
 	} else if regIdx.High {
-		c.Registers[regIdx.Name] = val.Value << 8
+		c.Registers[regIdx.Name] = (curr & 0x00ff) | ((val.Value & 0xff) << 8)
 	} else {
-		c.Registers[regIdx.Name] = int(int8(val.Value))
+		c.Registers[regIdx.Name] = (curr & 0xff00) | (val.Value & 0xff)
 	}
 	return nil
 }
@@ -58,12 +69,53 @@ func (c *Computer8086) AccessRegister(reg instruction.InstructionOperand) Comput
 	case instruction.Operand_Register:
 		r := c.ResolveRegister(reg.Register)
 		val := c.Registers[r.Name]
+		if r.High {
+			val = (val >> 8) & 0xff
+		} else if !r.Wide {
+			val &= 0xff
+		}
 		return ComputerRegister{Value: val}
 	case instruction.Operand_Memory:
 	}
 	return ComputerRegister{}
 }
 
+// PhysicalAddress computes the 20-bit address of a memory operand. The
+// segment comes from the override prefix when there is one, otherwise from
+// SS for BP-based expressions and DS for everything else.
+func (c *Computer8086) PhysicalAddress(eae instruction.EffectiveAddressExpression) int {
+	offset := eae.DisplacementValue
+	for _, term := range eae.Terms {
+		if term.Name != "" {
+			offset += c.Registers[term.Name]
+		}
+	}
+
+	segment := "DS"
+	if eae.Terms[0].Name == "BP" {
+		segment = "SS"
+	}
+	if eae.Segment.Name != "" {
+		segment = eae.Segment.Name
+	}
+
+	return ((c.Registers[segment] << 4) + (offset & 0xffff)) & (MemorySize - 1)
+}
+
+func (c *Computer8086) ReadMemory(addr int, size int) int {
+	val := 0
+	for i := range size {
+		val |= int(c.Memory[(addr+i)&(MemorySize-1)]) << (8 * i)
+	}
+	return val
+}
+
+func (c *Computer8086) WriteMemory(addr int, size int, val int) {
+	for i := range size {
+		c.Memory[(addr+i)&(MemorySize-1)] = byte(val >> (8 * i))
+	}
+}
+
 func (c *Computer8086) ResolveRegister(reg instruction.Register) ComputerRegister {
 	switch reg.Name {
 	case "AX":
@@ -91,13 +143,21 @@ func (c *Computer8086) ResolveRegister(reg instruction.Register) ComputerRegiste
 	case "DH":
 		return ComputerRegister{Name: "DX", High: true}
 	case "SP":
-		return ComputerRegister{Name: "SP"}
+		return ComputerRegister{Name: "SP", Wide: true}
 	case "BP":
-		return ComputerRegister{Name: "BP"}
+		return ComputerRegister{Name: "BP", Wide: true}
 	case "SI":
-		return ComputerRegister{Name: "SI"}
+		return ComputerRegister{Name: "SI", Wide: true}
 	case "DI":
-		return ComputerRegister{Name: "DI"}
+		return ComputerRegister{Name: "DI", Wide: true}
+	case "ES":
+		return ComputerRegister{Name: "ES", Wide: true}
+	case "CS":
+		return ComputerRegister{Name: "CS", Wide: true}
+	case "SS":
+		return ComputerRegister{Name: "SS", Wide: true}
+	case "DS":
+		return ComputerRegister{Name: "DS", Wide: true}
 	}
 
 	return ComputerRegister{}
@@ -0,0 +1,73 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/memimage"
+	"github.com/juanpablocruz/sim8086/pkg/vm"
+)
+
+func TestExec_SegmentOverride(t *testing.T) {
+	c := vm.New()
+	c.Registers["DS"] = 0x1000
+	c.Registers["ES"] = 0x2000
+	c.Registers["BX"] = 0x10
+	c.WriteMemory(0x10010, 2, 0x1111)
+	c.WriteMemory(0x20010, 2, 0x2222)
+
+	ax := instruction.InstructionOperand{Type: instruction.Operand_Register, Register: instruction.Register{Name: "AX"}}
+	mem := instruction.InstructionOperand{
+		Type: instruction.Operand_Memory,
+		EffectiveAddressExpression: instruction.EffectiveAddressExpression{
+			Terms: [2]instruction.Register{{Name: "BX", Code: 3}},
+		},
+	}
+
+	c.ExecInstruction(instruction.Instruction{Op: instruction.Op_mov, Wide: true, Reg: ax, RM: mem})
+	if got := c.Registers["AX"]; got != 0x1111 {
+		t.Errorf("default segment: got=0x%04x want=0x1111", got)
+	}
+
+	mem.Segment = instruction.Register{Name: "ES", Code: 0, Class: instruction.Register_Segment}
+	c.ExecInstruction(instruction.Instruction{Op: instruction.Op_mov, Wide: true, Reg: ax, RM: mem})
+	if got := c.Registers["AX"]; got != 0x2222 {
+		t.Errorf("es override: got=0x%04x want=0x2222", got)
+	}
+
+	c.Registers["AX"] = 0x3333
+	c.ExecInstruction(instruction.Instruction{Op: instruction.Op_mov, Wide: true, Reg: mem, RM: ax})
+	if got := c.ReadMemory(0x20010, 2); got != 0x3333 {
+		t.Errorf("es override write: got=0x%04x want=0x3333", got)
+	}
+	if got := c.ReadMemory(0x10010, 2); got != 0x1111 {
+		t.Errorf("es override write leaked into ds: got=0x%04x want=0x1111", got)
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	c := vm.New()
+	c.Memory[0x104] = 0xaa
+
+	img := &memimage.Image{Segments: []memimage.Segment{
+		{Address: 0x100, Data: []byte{0x34, 0x12}},
+		{Address: 0xffff0, Data: []byte{0xea}},
+	}}
+	if err := c.LoadImage(img); err != nil {
+		t.Fatalf("LoadImage: %v", err)
+	}
+	if got := c.ReadMemory(0x100, 2); got != 0x1234 {
+		t.Errorf("segment at 0x100: got=0x%04x want=0x1234", got)
+	}
+	if got := c.ReadMemory(0xffff0, 1); got != 0xea {
+		t.Errorf("segment at 0xffff0: got=0x%02x want=0xea", got)
+	}
+	if got := c.ReadMemory(0x104, 1); got != 0xaa {
+		t.Errorf("gap was overwritten: got=0x%02x want=0xaa", got)
+	}
+
+	img.Segments = append(img.Segments, memimage.Segment{Address: 0xfffff, Data: []byte{0, 0}})
+	if err := c.LoadImage(img); err == nil {
+		t.Errorf("segment past 1MB expected an error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/memimage"
 	"github.com/juanpablocruz/sim8086/pkg/options"
 )
 
@@ -17,12 +18,17 @@ type ComputerRegister struct {
 	Value int
 }
 
+// MemorySize is the 1MB physical address space reachable with 20 address lines.
+const MemorySize = 1 << 20
+
 type Computer8086 struct {
 	Registers map[string]int
+	Memory    []byte
 }
 
 func New() *Computer8086 {
 	c := Computer8086{}
+	c.Memory = make([]byte, MemorySize)
 	c.Registers = map[string]int{
 		"AX": 0,
 		"BX": 0,
@@ -32,10 +38,26 @@ func New() *Computer8086 {
 		"BP": 0,
 		"SI": 0,
 		"DI": 0,
+		"ES": 0,
+		"CS": 0,
+		"SS": 0,
+		"DS": 0,
 	}
 	return &c
 }
 
+// LoadImage copies every segment of img to its physical address. Memory in
+// the gaps between segments is left untouched.
+func (c *Computer8086) LoadImage(img *memimage.Image) error {
+	for _, s := range img.Segments {
+		if s.Address < 0 || s.End() > MemorySize {
+			return fmt.Errorf("segment 0x%05x-0x%05x is outside the 1MB address space", s.Address, s.End()-1)
+		}
+		copy(c.Memory[s.Address:], s.Data)
+	}
+	return nil
+}
+
 func (c *Computer8086) PrintRegisters(out *bytes.Buffer, pad ...int) {
 	padding := 0
 	if len(pad) == 1 {
@@ -0,0 +1,143 @@
+// Package xref indexes which instructions reference which addresses, both
+// as branch targets and as direct memory operands.
+package xref
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+)
+
+type RefKind int
+
+const (
+	Ref_Jump RefKind = iota
+	Ref_Call
+	Ref_Read
+	Ref_Write
+	// Ref_Address takes the address without accessing it, as lea does.
+	Ref_Address
+)
+
+func (k RefKind) String() string {
+	switch k {
+	case Ref_Call:
+		return "call"
+	case Ref_Read:
+		return "read"
+	case Ref_Write:
+		return "write"
+	case Ref_Address:
+		return "address"
+	default:
+		return "jump"
+	}
+}
+
+// Ref is one reference to To made by the instruction at From. Memory
+// references carry the size of the access and the segment override they
+// were made through, if any.
+type Ref struct {
+	From    int
+	To      int
+	Kind    RefKind
+	Size    int
+	Segment string
+
+	Instruction instruction.Instruction
+}
+
+type Index struct {
+	refs map[int][]Ref
+}
+
+// Build indexes the branch and call targets of instrs and their direct
+// [disp] memory operands. An instruction that both reads and writes its
+// operand, like add [0x10], ax, gives a read and a write reference.
+func Build(instrs []instruction.Instruction) *Index {
+	x := &Index{refs: map[int][]Ref{}}
+
+	for _, in := range instrs {
+		if target, ok := in.BranchTarget(); ok {
+			kind := Ref_Jump
+			if in.Semantics().Branch == instruction.Branch_Call {
+				kind = Ref_Call
+			}
+			x.add(Ref{From: in.Address, To: target, Kind: kind, Instruction: in})
+		}
+
+		for n, op := range []instruction.InstructionOperand{in.Reg, in.RM} {
+			if op.Type != instruction.Operand_Memory || op.Terms[0].Name != "" || op.Terms[1].Name != "" {
+				continue
+			}
+
+			ref := Ref{From: in.Address, To: op.DisplacementValue & 0xffff, Size: 1, Segment: strings.ToLower(op.Segment.Name), Instruction: in}
+			if in.Wide {
+				ref.Size = 2
+			}
+			if in.Flags&instruction.Inst_Far == instruction.Inst_Far || in.Op == instruction.Op_lds || in.Op == instruction.Op_les {
+				ref.Size = 4
+			}
+
+			access := in.OperandAccess(n)
+			if access&instruction.Access_Address != 0 {
+				ref.Kind = Ref_Address
+				x.add(ref)
+			}
+			if access&instruction.Access_Read != 0 {
+				ref.Kind = Ref_Read
+				x.add(ref)
+			}
+			if access&instruction.Access_Write != 0 {
+				ref.Kind = Ref_Write
+				x.add(ref)
+			}
+		}
+	}
+	return x
+}
+
+func (x *Index) add(ref Ref) {
+	x.refs[ref.To] = append(x.refs[ref.To], ref)
+}
+
+// To returns the references to address in the order the instructions came.
+func (x *Index) To(address int) []Ref {
+	return x.refs[address]
+}
+
+// Targets returns every referenced address in ascending order.
+func (x *Index) Targets() []int {
+	targets := make([]int, 0, len(x.refs))
+	for target := range x.refs {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+// WriteReport writes one line per reference, grouped by target address.
+func (x *Index) WriteReport(w io.Writer) error {
+	var out strings.Builder
+
+	for _, target := range x.Targets() {
+		out.WriteString(fmt.Sprintf("0x%04x:\n", target))
+		for _, ref := range x.refs[target] {
+			detail := ""
+			switch ref.Kind {
+			case Ref_Read, Ref_Write, Ref_Address:
+				detail = fmt.Sprintf(" %d", ref.Size)
+				if ref.Segment != "" {
+					detail += " " + ref.Segment
+				}
+			}
+			out.WriteString(fmt.Sprintf("\t%-7s 0x%04x%-6s  %s\n", ref.Kind, ref.From, detail, ref.Instruction))
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
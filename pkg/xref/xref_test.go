@@ -0,0 +1,80 @@
+package xref_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/juanpablocruz/sim8086/pkg/instruction"
+	"github.com/juanpablocruz/sim8086/pkg/lexer"
+	"github.com/juanpablocruz/sim8086/pkg/reader"
+	"github.com/juanpablocruz/sim8086/pkg/xref"
+)
+
+func decode(t *testing.T, data []byte) []instruction.Instruction {
+	t.Helper()
+	r := reader.NewFromBytes(data)
+	r.Origin = 0x100
+	instrs, err := lexer.New(r).All()
+	if err != nil {
+		t.Fatalf("decoding % x: %v", data, err)
+	}
+	return instrs
+}
+
+func TestBuild(t *testing.T) {
+	x := xref.Build(decode(t, []byte{
+		0xa1, 0x00, 0x02, // mov ax, [0x200]
+		0x01, 0x06, 0x00, 0x02, // add [0x200], ax
+		0x26, 0x88, 0x26, 0x02, 0x02, // mov [es:0x202], ah
+		0x8d, 0x1e, 0x00, 0x02, // lea bx, [0x200]
+		0xe8, 0x02, 0x00, // call 0x115
+		0xeb, 0xfe, // jmp 0x113
+		0x75, 0xfc, // jne 0x113
+		0x8b, 0x47, 0x10, // mov ax, [bx + 16], not direct
+	}))
+
+	if got := x.Targets(); len(got) != 4 || got[0] != 0x113 || got[1] != 0x115 || got[2] != 0x200 || got[3] != 0x202 {
+		t.Fatalf("Targets(). got=%#x", got)
+	}
+
+	tests := []struct {
+		target int
+		want   []xref.Ref
+	}{
+		{0x113, []xref.Ref{{From: 0x113, To: 0x113, Kind: xref.Ref_Jump}, {From: 0x115, To: 0x113, Kind: xref.Ref_Jump}}},
+		{0x115, []xref.Ref{{From: 0x110, To: 0x115, Kind: xref.Ref_Call}}},
+		{0x200, []xref.Ref{
+			{From: 0x100, To: 0x200, Kind: xref.Ref_Read, Size: 2},
+			{From: 0x103, To: 0x200, Kind: xref.Ref_Read, Size: 2},
+			{From: 0x103, To: 0x200, Kind: xref.Ref_Write, Size: 2},
+			{From: 0x10c, To: 0x200, Kind: xref.Ref_Address, Size: 2},
+		}},
+		{0x202, []xref.Ref{{From: 0x107, To: 0x202, Kind: xref.Ref_Write, Size: 1, Segment: "es"}}},
+	}
+	for _, tt := range tests {
+		got := x.To(tt.target)
+		if len(got) != len(tt.want) {
+			t.Errorf("To(0x%x). got %d refs want %d", tt.target, len(got), len(tt.want))
+			continue
+		}
+		for n, ref := range got {
+			want := tt.want[n]
+			if ref.From != want.From || ref.To != want.To || ref.Kind != want.Kind || ref.Size != want.Size || ref.Segment != want.Segment {
+				t.Errorf("To(0x%x)[%d]. got=%+v want=%+v", tt.target, n, ref, tt.want[n])
+			}
+		}
+	}
+
+	var report strings.Builder
+	if err := x.WriteReport(&report); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+	for _, line := range []string{
+		"0x0115:\n\tcall    0x0110        call $+5\n",
+		"\twrite   0x0107 1 es   mov [es:514], ah\n",
+	} {
+		if !strings.Contains(report.String(), line) {
+			t.Errorf("WriteReport() missing %q in\n%s", line, report.String())
+		}
+	}
+}